@@ -0,0 +1,273 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+const (
+	defaultAsyncBufferSize         = 1024
+	defaultAsyncDropReportInterval = 10 * time.Second
+	defaultAsyncDropBelowLevel     = zerolog.WarnLevel
+)
+
+var errAsyncWriterClosed = errors.New("async writer is closed")
+
+// OverflowPolicy defines how an AsyncWriter behaves when its buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until there is room in the buffer
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the log line being written
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest log line in the buffer to make room
+	OverflowDropOldest
+	// OverflowDropBelowLevel drops the log line being written when its level
+	// is lower than AsyncOptions.DropBelowLevel, otherwise it waits
+	OverflowDropBelowLevel
+)
+
+// AsyncOptions are the possible options that can be used to create an AsyncWriter
+type AsyncOptions struct {
+	// BufferSize is the number of log lines the buffer can hold, 1024 by default
+	BufferSize int
+	// Overflow selects the behavior when the buffer is full, OverflowBlock by default
+	Overflow OverflowPolicy
+	// DropBelowLevel is the lowest level that is never dropped by OverflowDropBelowLevel policy,
+	// identified by the names accepted by InitOptions.Level, "warn" by default
+	DropBelowLevel string
+	// DropReportInterval is how often the number of dropped log lines is logged, 10 seconds by default
+	DropReportInterval time.Duration
+}
+
+type asyncEntry struct {
+	level zerolog.Level
+	line  []byte
+}
+
+// AsyncWriter is an io.Writer that hands log lines over to a background goroutine,
+// so that loggers are not blocked by a slow destination. When log lines are dropped,
+// a warning reporting their number is periodically written to the destination.
+type AsyncWriter struct {
+	out       zerolog.LevelWriter
+	options   AsyncOptions
+	dropBelow zerolog.Level
+	notifier  *zerolog.Logger
+
+	mu      sync.RWMutex
+	closed  bool
+	entries chan asyncEntry
+	flushes chan chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// NewAsyncWriter creates an AsyncWriter writing to w and starts its background goroutine
+func NewAsyncWriter(w io.Writer, options AsyncOptions) (*AsyncWriter, error) {
+	return newAsyncWriter(w, options, defaultFormat())
+}
+
+// newAsyncWriter creates an AsyncWriter reporting dropped lines with the given format
+func newAsyncWriter(w io.Writer, options AsyncOptions, logFormat format) (*AsyncWriter, error) {
+	dropBelow := defaultAsyncDropBelowLevel
+	if options.DropBelowLevel != "" {
+		level, err := pino.ParseLevel(options.DropBelowLevel)
+		if err != nil {
+			return nil, fmt.Errorf("async: %w", err)
+		}
+		dropBelow = level
+	}
+
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaultAsyncBufferSize
+	}
+	if options.DropReportInterval <= 0 {
+		options.DropReportInterval = defaultAsyncDropReportInterval
+	}
+
+	aw := &AsyncWriter{
+		out:       levelWriter(w),
+		options:   options,
+		dropBelow: dropBelow,
+		// the notifier is used only by the background goroutine, so it can write directly
+		notifier: createLogger(w, zerolog.TraceLevel, logFormat),
+		entries:  make(chan asyncEntry, options.BufferSize),
+		flushes:  make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go aw.run()
+
+	return aw, nil
+}
+
+// Write implements io.Writer interface
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	return aw.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter interface. The log line is copied,
+// so the caller can reuse p, and dropped lines are not reported as errors.
+func (aw *AsyncWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+
+	if aw.closed {
+		return 0, errAsyncWriterClosed
+	}
+
+	entry := asyncEntry{level: level, line: append([]byte(nil), p...)}
+
+	switch aw.options.Overflow {
+	case OverflowDropNewest:
+		aw.tryEnqueue(entry)
+	case OverflowDropOldest:
+		aw.enqueueDroppingOldest(entry)
+	case OverflowDropBelowLevel:
+		if !pino.Enabled(level, aw.dropBelow) {
+			aw.tryEnqueue(entry)
+			break
+		}
+		aw.entries <- entry
+	default:
+		aw.entries <- entry
+	}
+
+	return len(p), nil
+}
+
+// tryEnqueue adds the entry to the buffer when there is room, counting it as dropped otherwise
+func (aw *AsyncWriter) tryEnqueue(entry asyncEntry) {
+	select {
+	case aw.entries <- entry:
+	default:
+		aw.dropped.Add(1)
+	}
+}
+
+// enqueueDroppingOldest adds the entry to the buffer, dropping the oldest entries until there is room
+func (aw *AsyncWriter) enqueueDroppingOldest(entry asyncEntry) {
+	for {
+		select {
+		case aw.entries <- entry:
+			return
+		default:
+		}
+
+		select {
+		case <-aw.entries:
+			aw.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// Flush waits until all the log lines buffered so far have been written
+func (aw *AsyncWriter) Flush() {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+
+	if aw.closed {
+		return
+	}
+
+	flushed := make(chan struct{})
+	aw.flushes <- flushed
+	<-flushed
+}
+
+// Close stops accepting log lines and waits until the buffered ones have been written.
+// The destination is not closed, since the AsyncWriter does not own it.
+func (aw *AsyncWriter) Close() error {
+	aw.mu.Lock()
+	if aw.closed {
+		aw.mu.Unlock()
+		return nil
+	}
+	aw.closed = true
+	close(aw.entries)
+	aw.mu.Unlock()
+
+	<-aw.done
+	return nil
+}
+
+// Dropped returns the number of log lines dropped and not reported yet
+func (aw *AsyncWriter) Dropped() int64 {
+	return aw.dropped.Load()
+}
+
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+
+	ticker := time.NewTicker(aw.options.DropReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case entry, ok := <-aw.entries:
+			if !ok {
+				aw.reportDropped()
+				return
+			}
+			aw.write(entry)
+		case flushed := <-aw.flushes:
+			aw.drain()
+			close(flushed)
+		case <-ticker.C:
+			aw.reportDropped()
+		}
+	}
+}
+
+// drain writes all the log lines currently in the buffer
+func (aw *AsyncWriter) drain() {
+	for {
+		select {
+		case entry, ok := <-aw.entries:
+			if !ok {
+				return
+			}
+			aw.write(entry)
+		default:
+			return
+		}
+	}
+}
+
+func (aw *AsyncWriter) write(entry asyncEntry) {
+	// errors are ignored, since there is no caller they could be reported to
+	_, _ = aw.out.WriteLevel(entry.level, entry.line)
+}
+
+func (aw *AsyncWriter) reportDropped() {
+	if dropped := aw.dropped.Swap(0); dropped > 0 {
+		aw.notifier.Warn().
+			Int64("dropped", dropped).
+			Msg(fmt.Sprintf("%d log lines dropped", dropped))
+	}
+}
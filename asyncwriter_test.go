@@ -0,0 +1,247 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+// gatedWriter blocks every write until the gate is opened
+type gatedWriter struct {
+	gate chan struct{}
+
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{gate: make(chan struct{})}
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buffer.Write(p)
+}
+
+func (w *gatedWriter) Open() {
+	close(w.gate)
+}
+
+func (w *gatedWriter) Lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Split(strings.TrimSpace(w.buffer.String()), "\n")
+}
+
+type droppedLog struct {
+	miaLog
+	Dropped int64 `json:"dropped"`
+}
+
+func TestAsyncWriter(t *testing.T) {
+	t.Run("Write buffered lines on close", func(t *testing.T) {
+		out := newGatedWriter()
+		out.Open()
+		logger, stop, err := InitWithStop(InitOptions{Writer: out, Async: &AsyncOptions{}})
+		require.NoError(t, err)
+
+		for i := 0; i < 100; i++ {
+			logger.Info().Int("line", i).Msg(message)
+		}
+		stop()
+
+		lines := out.Lines()
+		require.Len(t, lines, 100)
+		for i, line := range lines {
+			require.Contains(t, line, fmt.Sprintf(`"line":%d`, i), "Lines keep their order")
+		}
+	})
+
+	t.Run("Flush buffered lines", func(t *testing.T) {
+		out := newGatedWriter()
+		writer, err := NewAsyncWriter(out, AsyncOptions{})
+		require.NoError(t, err)
+
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err)
+
+		out.Open()
+		writer.Flush()
+		require.Equal(t, []string{strings.TrimSpace(logLine)}, out.Lines())
+
+		require.NoError(t, writer.Close())
+		_, err = writer.Write([]byte(logLine))
+		require.ErrorIs(t, err, errAsyncWriterClosed, "Closed writer does not accept lines")
+	})
+
+	t.Run("Drop newest lines and report them", func(t *testing.T) {
+		out := newGatedWriter()
+		writer, err := NewAsyncWriter(out, AsyncOptions{BufferSize: 2, Overflow: OverflowDropNewest})
+		require.NoError(t, err)
+
+		writeLines(t, writer, zerolog.InfoLevel, 0, 1)
+		// wait for the background goroutine to hold the first line
+		require.Eventually(t, func() bool {
+			return len(writer.entries) == 0
+		}, time.Second, 5*time.Millisecond)
+		writeLines(t, writer, zerolog.InfoLevel, 1, 6)
+		require.Equal(t, int64(3), writer.Dropped())
+
+		out.Open()
+		require.NoError(t, writer.Close())
+
+		lines := out.Lines()
+		require.Len(t, lines, 4)
+		require.Contains(t, lines[2], `"line":2`, "Newest lines are dropped")
+		verifyDroppedReport(t, lines[3], 3)
+	})
+
+	t.Run("Drop oldest lines", func(t *testing.T) {
+		out := newGatedWriter()
+		writer, err := NewAsyncWriter(out, AsyncOptions{BufferSize: 2, Overflow: OverflowDropOldest})
+		require.NoError(t, err)
+
+		writeLines(t, writer, zerolog.InfoLevel, 0, 1)
+		// wait for the background goroutine to hold the first line
+		require.Eventually(t, func() bool {
+			return len(writer.entries) == 0
+		}, time.Second, 5*time.Millisecond)
+		writeLines(t, writer, zerolog.InfoLevel, 1, 6)
+
+		out.Open()
+		require.NoError(t, writer.Close())
+
+		lines := out.Lines()
+		require.Len(t, lines, 4)
+		require.Contains(t, lines[0], `"line":0`)
+		require.Contains(t, lines[1], `"line":4`, "Oldest lines are dropped")
+		require.Contains(t, lines[2], `"line":5`)
+		verifyDroppedReport(t, lines[3], 3)
+	})
+
+	t.Run("Drop lines below level", func(t *testing.T) {
+		out := newGatedWriter()
+		writer, err := NewAsyncWriter(out, AsyncOptions{BufferSize: 1, Overflow: OverflowDropBelowLevel})
+		require.NoError(t, err)
+
+		writeLines(t, writer, zerolog.InfoLevel, 0, 1)
+		require.Eventually(t, func() bool {
+			return len(writer.entries) == 0
+		}, time.Second, 5*time.Millisecond)
+		writeLines(t, writer, zerolog.InfoLevel, 1, 2)
+		writeLines(t, writer, zerolog.InfoLevel, 2, 4)
+		require.Equal(t, int64(2), writer.Dropped(), "Info lines are dropped below warn level by default")
+
+		written := make(chan struct{})
+		go func() {
+			defer close(written)
+			writeLines(t, writer, zerolog.ErrorLevel, 4, 5)
+		}()
+
+		select {
+		case <-written:
+			t.Fatal("Error lines wait for room in the buffer")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		out.Open()
+		<-written
+		require.NoError(t, writer.Close())
+
+		lines := out.Lines()
+		require.Len(t, lines, 4)
+		require.Contains(t, lines[2], `"line":4`)
+		verifyDroppedReport(t, lines[3], 2)
+	})
+
+	t.Run("Drop lines below the given level", func(t *testing.T) {
+		out := newGatedWriter()
+		writer, err := NewAsyncWriter(out, AsyncOptions{
+			BufferSize:     1,
+			Overflow:       OverflowDropBelowLevel,
+			DropBelowLevel: "error",
+		})
+		require.NoError(t, err)
+
+		writeLines(t, writer, zerolog.WarnLevel, 0, 1)
+		require.Eventually(t, func() bool {
+			return len(writer.entries) == 0
+		}, time.Second, 5*time.Millisecond)
+		writeLines(t, writer, zerolog.WarnLevel, 1, 3)
+		require.Equal(t, int64(1), writer.Dropped(), "Warn lines are dropped below error level")
+
+		out.Open()
+		require.NoError(t, writer.Close())
+	})
+
+	t.Run("Reject unknown drop level", func(t *testing.T) {
+		writer, err := NewAsyncWriter(newGatedWriter(), AsyncOptions{DropBelowLevel: "verbose"})
+		require.Nil(t, writer)
+		require.EqualError(t, err, "async: level verbose is not recognized")
+	})
+
+	t.Run("Report dropped lines periodically", func(t *testing.T) {
+		out := newGatedWriter()
+		writer, err := NewAsyncWriter(out, AsyncOptions{
+			BufferSize:         1,
+			Overflow:           OverflowDropNewest,
+			DropReportInterval: 10 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		defer writer.Close()
+
+		writeLines(t, writer, zerolog.InfoLevel, 0, 4)
+		out.Open()
+
+		require.Eventually(t, func() bool {
+			lines := out.Lines()
+			return strings.Contains(lines[len(lines)-1], "log lines dropped")
+		}, time.Second, 5*time.Millisecond)
+	})
+}
+
+func writeLines(t testing.TB, writer zerolog.LevelWriter, level zerolog.Level, from, to int) {
+	t.Helper()
+
+	for i := from; i < to; i++ {
+		_, err := writer.WriteLevel(level, []byte(fmt.Sprintf("{\"line\":%d}\n", i)))
+		require.NoError(t, err)
+	}
+}
+
+func verifyDroppedReport(t testing.TB, line string, dropped int64) {
+	t.Helper()
+
+	result := droppedLog{}
+	require.Nil(t, json.Unmarshal([]byte(line), &result), "No error raised")
+	verifyLog(t, &result.miaLog, fmt.Sprintf("%d log lines dropped", dropped), string(pino.Warn), unixTimestampMsLen)
+	require.Equal(t, dropped, result.Dropped)
+}
@@ -0,0 +1,219 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	zp "github.com/danibix95/zeropino"
+	"github.com/danibix95/zeropino/pino"
+)
+
+// fieldMatches collects the -field flags, each one requiring a field to have a value
+type fieldMatches []fieldMatch
+
+type fieldMatch struct {
+	path  []string
+	value string
+}
+
+// String implements flag.Value interface
+func (m *fieldMatches) String() string {
+	matches := make([]string, 0, len(*m))
+	for _, match := range *m {
+		matches = append(matches, strings.Join(match.path, ".")+"="+match.value)
+	}
+	return strings.Join(matches, ",")
+}
+
+// Set implements flag.Value interface
+func (m *fieldMatches) Set(value string) error {
+	key, expected, found := strings.Cut(value, "=")
+	if !found || key == "" {
+		return fmt.Errorf("field %s must be written as key=value", value)
+	}
+
+	*m = append(*m, fieldMatch{path: strings.Split(key, "."), value: expected})
+	return nil
+}
+
+type filterOptions struct {
+	level   string
+	reqID   string
+	since   string
+	until   string
+	message string
+	timeKey string
+	fields  fieldMatches
+}
+
+// filter selects the logs to write. Lines that are not JSON objects always match.
+type filter struct {
+	minLevel int
+	reqID    string
+	since    time.Time
+	until    time.Time
+	message  string
+	timeKey  string
+	fields   fieldMatches
+}
+
+func newFilter(options filterOptions) (filter, error) {
+	f := filter{reqID: options.reqID, message: options.message, timeKey: options.timeKey, fields: options.fields}
+	var errs []error
+
+	if options.level != "" {
+		level, err := pino.ParseLevel(options.level)
+		if err != nil {
+			errs = append(errs, err)
+		} else if f.minLevel, _ = pino.LevelValue(level); f.minLevel == 0 {
+			errs = append(errs, fmt.Errorf("level %s cannot be used as minimum level", options.level))
+		}
+	}
+
+	now := time.Now()
+	var err error
+	if f.since, err = parseTimeBound(options.since, now); err != nil {
+		errs = append(errs, fmt.Errorf("since: %w", err))
+	}
+	if f.until, err = parseTimeBound(options.until, now); err != nil {
+		errs = append(errs, fmt.Errorf("until: %w", err))
+	}
+
+	return f, errors.Join(errs...)
+}
+
+// parseTimeBound parses either an RFC 3339 time or a duration before now
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is neither an RFC 3339 time nor a duration", value)
+	}
+	return t, nil
+}
+
+// match reports whether the log line satisfies all the filter conditions
+func (f filter) match(line []byte) bool {
+	entry := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&entry); err != nil {
+		return true
+	}
+
+	if f.minLevel > 0 {
+		if level, ok := levelValue(entry["level"]); !ok || level < f.minLevel {
+			return false
+		}
+	}
+
+	if f.reqID != "" && fmt.Sprint(entry["reqId"]) != f.reqID {
+		return false
+	}
+
+	if f.message != "" {
+		message, _ := entry["msg"].(string)
+		if !strings.Contains(message, f.message) {
+			return false
+		}
+	}
+
+	if !f.since.IsZero() || !f.until.IsZero() {
+		t, ok := entryTime(entry[f.timeKey])
+		if !ok || (!f.since.IsZero() && t.Before(f.since)) || (!f.until.IsZero() && t.After(f.until)) {
+			return false
+		}
+	}
+
+	for _, field := range f.fields {
+		value, ok := lookup(entry, field.path)
+		if !ok || fmt.Sprint(value) != field.value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// levelValue returns the pino value of a level, written either as a number or as a string
+func levelValue(value interface{}) (int, bool) {
+	var text string
+	switch level := value.(type) {
+	case json.Number:
+		text = level.String()
+	case string:
+		text = level
+	default:
+		return 0, false
+	}
+
+	if text == "" {
+		return 0, false
+	}
+	level, err := pino.ParseLevel(text)
+	if err != nil {
+		return 0, false
+	}
+	return pino.LevelValue(level)
+}
+
+// entryTime returns the time of a log, written either as a Unix timestamp
+// in seconds, milliseconds or nanoseconds, or as an RFC 3339 string
+func entryTime(value interface{}) (time.Time, bool) {
+	switch t := value.(type) {
+	case json.Number:
+		epoch, err := strconv.ParseInt(t.String(), 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return zp.EpochTime(epoch), true
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		return parsed, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+// lookup returns the value of the nested field identified by path
+func lookup(entry map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = entry
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,134 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+// Command zeropino pretty-prints and filters the NDJSON logs produced by zeropino and pino,
+// reading them from the standard input or from files. Lines that are not JSON objects
+// are always written unchanged.
+//
+// Usage:
+//
+//	zeropino [flags] [file ...]
+//
+// For example, to follow a log file showing only the failed requests:
+//
+//	zeropino -f -level warn -field http.response.statusCode=500 service.log
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	zp "github.com/danibix95/zeropino"
+	"github.com/danibix95/zeropino/pino"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command with the given arguments, returning its exit code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("zeropino", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: zeropino [flags] [file ...]")
+		fmt.Fprintln(stderr, "Pretty-print and filter zeropino and pino logs read from files or, "+
+			"when none is given, from the standard input.")
+		flags.PrintDefaults()
+	}
+
+	var options filterOptions
+	follow := flags.Bool("f", false, "keep reading the files as they grow, as tail -f does")
+	raw := flags.Bool("raw", false,
+		"write the matching lines as they are, without pretty-printing them")
+	noColor := flags.Bool("no-color", false,
+		"disable colors, which are enabled only when writing to a terminal")
+	flags.StringVar(&options.level, "level", "",
+		"minimum level of the logs, one of "+strings.Join(pino.LevelNames(), ", ")+" or a pino value")
+	flags.StringVar(&options.reqID, "req-id", "", "keep only the logs with the given reqId")
+	flags.StringVar(&options.since, "since", "",
+		"keep only the logs written from the given time, either RFC 3339 or a duration before now, e.g. 15m")
+	flags.StringVar(&options.until, "until", "",
+		"keep only the logs written up to the given time, either RFC 3339 or a duration before now")
+	flags.StringVar(&options.message, "msg", "", "keep only the logs whose message contains the given text")
+	flags.StringVar(&options.timeKey, "time-key", "time", "key of the time field")
+	flags.Var(&options.fields, "field", "keep only the logs whose field has the given value, "+
+		"e.g. http.request.method=GET, where nested keys are separated by dots (repeatable)")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	logFilter, err := newFilter(options)
+	if err != nil {
+		fmt.Fprintf(stderr, "zeropino: %s\n", err)
+		return 2
+	}
+
+	var out io.Writer = stdout
+	if !*raw {
+		out = zp.NewPrettyWriter(stdout, zp.PrettyOptions{
+			NoColor: *noColor || !isTerminal(stdout),
+			TimeKey: options.timeKey,
+		})
+	}
+
+	var mu sync.Mutex
+	handle := func(line []byte) error {
+		if !logFilter.match(line) {
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := out.Write(line)
+		return err
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	if err := readAll(ctx, files, *follow, stdin, handle); err != nil {
+		fmt.Fprintf(stderr, "zeropino: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// isTerminal reports whether w is a terminal, which colors are written to by default
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
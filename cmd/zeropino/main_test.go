@@ -0,0 +1,215 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const logs = `{"level":30,"time":1618003000000,"reqId":"a","http":{"request":{"method":"GET"},"response":{"statusCode":200}},"msg":"request completed"}
+{"level":"50","time":1618003060000,"reqId":"b","http":{"request":{"method":"POST"},"response":{"statusCode":500}},"msg":"request failed"}
+panic: runtime error
+{"level":"debug","time":"2021-04-09T21:18:00Z","reqId":"a","msg":"reading the map"}
+`
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "Write all the lines",
+			args:     []string{"-raw"},
+			expected: strings.Split(strings.TrimSpace(logs), "\n"),
+		},
+		{
+			name:     "Filter by minimum level",
+			args:     []string{"-raw", "-level", "INFO"},
+			expected: []string{"request completed", "request failed", "panic: runtime error"},
+		},
+		{
+			name:     "Filter by minimum pino value",
+			args:     []string{"-raw", "-level", "50"},
+			expected: []string{"request failed", "panic: runtime error"},
+		},
+		{
+			name:     "Filter by request ID",
+			args:     []string{"-raw", "-req-id", "a"},
+			expected: []string{"request completed", "panic: runtime error", "reading the map"},
+		},
+		{
+			name:     "Filter by message",
+			args:     []string{"-raw", "-msg", "request"},
+			expected: []string{"request completed", "request failed", "panic: runtime error"},
+		},
+		{
+			name:     "Filter by time range",
+			args:     []string{"-raw", "-since", "2021-04-09T21:17:00Z", "-until", "2021-04-09T21:17:50Z"},
+			expected: []string{"request failed", "panic: runtime error"},
+		},
+		{
+			name:     "Filter by nested fields",
+			args:     []string{"-raw", "-field", "http.request.method=POST", "-field", "http.response.statusCode=500"},
+			expected: []string{"request failed", "panic: runtime error"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			code := run(context.Background(), testCase.args, strings.NewReader(logs), stdout, stderr)
+			require.Equal(t, 0, code, stderr.String())
+
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			require.Len(t, lines, len(testCase.expected))
+			for i, expected := range testCase.expected {
+				require.Contains(t, lines[i], expected)
+			}
+		})
+	}
+
+	t.Run("Pretty-print the lines read from files", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "service.log")
+		require.NoError(t, os.WriteFile(file, []byte(logs), 0o600))
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run(context.Background(), []string{"-level", "error", file}, strings.NewReader(""), stdout, stderr)
+		require.Equal(t, 0, code, stderr.String())
+
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		require.Len(t, lines, 2)
+		require.Regexp(t, `^\[\d{2}:\d{2}:\d{2}\.\d{3}\] ERROR \[b\]: request failed POST 500$`, lines[0])
+		require.Equal(t, "panic: runtime error", lines[1])
+	})
+
+	t.Run("Report invalid flags", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		args := []string{"-level", "verbose", "-since", "yesterday"}
+		code := run(context.Background(), args, strings.NewReader(""), stdout, stderr)
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr.String(), "level verbose is not recognized")
+		require.Contains(t, stderr.String(), "since: yesterday is neither an RFC 3339 time nor a duration")
+
+		code = run(context.Background(), []string{"-field", "statusCode"}, strings.NewReader(""), stdout, stderr)
+		require.Equal(t, 2, code)
+	})
+
+	t.Run("Report missing files", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		args := []string{filepath.Join(t.TempDir(), "missing.log")}
+		code := run(context.Background(), args, strings.NewReader(""), stdout, stderr)
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr.String(), "no such file or directory")
+	})
+}
+
+func TestFollow(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "service.log")
+	require.NoError(t, os.WriteFile(file, []byte(`{"level":30,"msg":"first"}`+"\n"), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stdout := &syncBuffer{}
+	done := make(chan int)
+	go func() {
+		done <- run(ctx, []string{"-f", "-raw", file}, strings.NewReader(""), stdout, &bytes.Buffer{})
+	}()
+
+	appendLine := func(line string) {
+		out, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0o600)
+		require.NoError(t, err)
+		_, err = out.WriteString(line)
+		require.NoError(t, err)
+		require.NoError(t, out.Close())
+	}
+
+	appendLine(`{"level":30,"msg":"sec`)
+	time.Sleep(2 * followInterval)
+	appendLine(`ond"}` + "\n")
+	require.Eventually(t, stdout.contains("second"), time.Second, 10*time.Millisecond)
+
+	appendLine(`{"level":30,"msg":"before `)
+	time.Sleep(2 * followInterval)
+	// the file is replaced before the end of its last lines is read
+	appendLine(`rotation"}` + "\n" + `{"level":30,"msg":"last"}` + "\n")
+	require.NoError(t, os.Rename(file, file+".1"))
+	require.NoError(t, os.WriteFile(file, []byte(`{"level":30,"msg":"rotated"}`+"\n"), 0o600))
+	require.Eventually(t, stdout.contains("rotated"), time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.Equal(t, 0, <-done)
+	require.Equal(t, `{"level":30,"msg":"first"}`+"\n"+`{"level":30,"msg":"second"}`+"\n"+
+		`{"level":30,"msg":"before rotation"}`+"\n"+`{"level":30,"msg":"last"}`+"\n"+
+		`{"level":30,"msg":"rotated"}`+"\n", stdout.String())
+}
+
+func TestFollowStandardInput(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stdin, input := io.Pipe()
+	defer input.Close()
+
+	stdout := &syncBuffer{}
+	done := make(chan int)
+	go func() {
+		done <- run(ctx, []string{"-f", "-raw"}, stdin, stdout, &bytes.Buffer{})
+	}()
+
+	_, err := input.Write([]byte(`{"level":30,"msg":"first"}` + "\n"))
+	require.NoError(t, err)
+	require.Eventually(t, stdout.contains("first"), time.Second, 10*time.Millisecond)
+
+	// the standard input is still open, yet the command stops
+	cancel()
+	select {
+	case code := <-done:
+		require.Equal(t, 0, code)
+	case <-time.After(time.Second):
+		require.Fail(t, "The command waits for the standard input to end")
+	}
+}
+
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
+
+// contains returns a condition reporting whether the text has been written to the buffer
+func (b *syncBuffer) contains(text string) func() bool {
+	return func() bool { return strings.Contains(b.String(), text) }
+}
@@ -0,0 +1,209 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const followInterval = 200 * time.Millisecond
+
+// readAll passes each line of the files to handle, where - is the standard input.
+// In follow mode the files are read concurrently and kept open until ctx is done,
+// while the standard input is read until its end or until ctx is done.
+func readAll(ctx context.Context, files []string, follow bool, stdin io.Reader, handle func([]byte) error) error {
+	if !follow {
+		for _, name := range files {
+			if err := readFile(name, stdin, handle); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make(chan error, len(files))
+	// a blocked read of the standard input cannot be interrupted,
+	// so its reader is not waited for once ctx is done
+	stdinErrs := make(chan error, len(files))
+	followed := 0
+	for _, name := range files {
+		if name == "-" {
+			go func() { stdinErrs <- readLines(stdin, handle) }()
+			continue
+		}
+
+		followed++
+		go func(name string) { errs <- followFile(ctx, name, handle) }(name)
+	}
+
+	var joined []error
+	for i := 0; i < followed; i++ {
+		if err := <-errs; err != nil {
+			joined = append(joined, err)
+		}
+	}
+	for i := followed; i < len(files); i++ {
+		select {
+		case err := <-stdinErrs:
+			if err != nil {
+				joined = append(joined, err)
+			}
+		case <-ctx.Done():
+			return errors.Join(joined...)
+		}
+	}
+	return errors.Join(joined...)
+}
+
+func readFile(name string, stdin io.Reader, handle func([]byte) error) error {
+	if name == "-" {
+		return readLines(stdin, handle)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := readLines(file, handle); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// readLines passes each line of r to handle, including the last one when it is not terminated
+func readLines(r io.Reader, handle func([]byte) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if handleErr := handle(line); handleErr != nil {
+				return handleErr
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// followFile passes each line of the file to handle, waiting for new lines once the end
+// of the file is reached. The file is read again from the beginning when it is truncated,
+// and it is reopened when it is replaced, e.g. by a log rotation.
+func followFile(ctx context.Context, name string, handle func([]byte) error) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+	var partial []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		partial = append(partial, line...)
+
+		if err == nil {
+			if err := handle(partial); err != nil {
+				return err
+			}
+			partial = nil
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(followInterval):
+		}
+
+		switch replaced, truncated := fileChanged(file, name); {
+		case replaced:
+			next, err := os.Open(name)
+			if err != nil {
+				// the new file may not be created yet
+				continue
+			}
+			// the lines written to the replaced file after its end has been reached are not lost
+			if err := drainLines(reader, partial, handle); err != nil {
+				_ = next.Close()
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			_ = file.Close()
+			file, partial = next, nil
+			reader.Reset(file)
+		case truncated:
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			partial = nil
+			reader.Reset(file)
+		}
+	}
+}
+
+// drainLines passes to handle the lines left in reader, the first one starting with partial,
+// including the last one when it is not terminated, as readLines does
+func drainLines(reader *bufio.Reader, partial []byte, handle func([]byte) error) error {
+	for {
+		line, err := reader.ReadBytes('\n')
+		partial = append(partial, line...)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if len(partial) > 0 {
+			if handleErr := handle(partial); handleErr != nil {
+				return handleErr
+			}
+			partial = nil
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// fileChanged reports whether the file at name is not the open one anymore
+// or whether the open one is shorter than the position it has been read up to
+func fileChanged(file *os.File, name string) (replaced, truncated bool) {
+	current, err := file.Stat()
+	if err != nil {
+		return false, false
+	}
+
+	if latest, err := os.Stat(name); err == nil && !os.SameFile(current, latest) {
+		return true, false
+	}
+
+	offset, err := file.Seek(0, io.SeekCurrent)
+	return false, err == nil && current.Size() < offset
+}
@@ -0,0 +1,241 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+// Environment variables read by InitFromEnv, to be prefixed with the given prefix
+const (
+	// EnvLevel selects the logger level, using the same names accepted by InitOptions.Level
+	EnvLevel = "LOG_LEVEL"
+	// EnvTimeFormat selects the time format, accepting the values of TimeFormat constants,
+	// e.g. "ms" (default) or "rfc3339"
+	EnvTimeFormat = "LOG_TIME_FORMAT"
+	// EnvTimeKey renames the time field
+	EnvTimeKey = "LOG_TIME_KEY"
+	// EnvOutput selects the logs destination, either "stdout" (default), "stderr" or a file path
+	EnvOutput = "LOG_OUTPUT"
+	// EnvDestinations lists comma separated destinations, accepting the values of EnvOutput,
+	// each optionally followed by = and its minimum level, e.g. "stdout=info,/var/log/errors.log=error"
+	EnvDestinations = "LOG_DESTINATIONS"
+	// EnvBase lists comma separated key=value fields added to every log, e.g. "service=shire,env=production"
+	EnvBase = "LOG_BASE"
+	// EnvPidKey renames the process id field
+	EnvPidKey = "LOG_PID_KEY"
+	// EnvHostnameKey renames the hostname field
+	EnvHostnameKey = "LOG_HOSTNAME_KEY"
+	// EnvOmitPid removes the process id field when true, accepting the values of strconv.ParseBool
+	EnvOmitPid = "LOG_OMIT_PID"
+	// EnvOmitHostname removes the hostname field when true, accepting the values of strconv.ParseBool
+	EnvOmitHostname = "LOG_OMIT_HOSTNAME"
+	// EnvLevelFormat selects the level format, either "string" (default), "number", "label" or "number+label"
+	EnvLevelFormat = "LOG_LEVEL_FORMAT"
+	// EnvLabelKey renames the label field written by "number+label" level format
+	EnvLabelKey = "LOG_LABEL_KEY"
+	// EnvLevelSignals enables the level changes on SIGUSR1 and SIGUSR2 when true,
+	// accepting the values of strconv.ParseBool
+	EnvLevelSignals = "LOG_LEVEL_SIGNALS"
+	// EnvErrorStack adds the stack of the code logging an error when true,
+	// accepting the values of strconv.ParseBool
+	EnvErrorStack = "LOG_ERROR_STACK"
+)
+
+const (
+	outputStdout = "stdout"
+	outputStderr = "stderr"
+)
+
+// InitFromEnv Creates a zerolog logger with custom default properties and custom style,
+// reading its options from the environment variables, whose names are built joining
+// the prefix (when not empty) and the variable name with an underscore,
+// e.g. MYAPP_LOG_LEVEL. All the invalid variables are reported together in the returned error.
+// The options that are not plain values, i.e. AtomicLevel, Async, Sampling and Redact, are not read.
+// The files opened for LOG_OUTPUT and LOG_DESTINATIONS are kept open until the program ends:
+// use InitFromEnvWithStop to close them earlier.
+func InitFromEnv(prefix string) (*zerolog.Logger, error) {
+	logger, _, err := InitFromEnvWithStop(prefix)
+	return logger, err
+}
+
+// InitFromEnvWithStop Creates a zerolog logger as InitFromEnv, returning also a function
+// that releases the resources installed by the options and closes the files opened for the logs
+func InitFromEnvWithStop(prefix string) (*zerolog.Logger, func(), error) {
+	options, err := optionsFromEnv(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger, stopLogger, err := InitWithStop(options)
+	if err != nil {
+		closeEnvFiles(options)
+		return nil, nil, err
+	}
+
+	return logger, func() {
+		stopLogger()
+		closeEnvFiles(options)
+	}, nil
+}
+
+func optionsFromEnv(prefix string) (InitOptions, error) {
+	var options InitOptions
+	var errs []error
+
+	levelName := envName(prefix, EnvLevel)
+	if level := os.Getenv(levelName); level != "" {
+		if _, err := pino.ParseLevel(level); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", levelName, err))
+		}
+		options.Level = level
+	}
+
+	timeFormatName := envName(prefix, EnvTimeFormat)
+	if timeFormat := os.Getenv(timeFormatName); timeFormat != "" {
+		if parsed, err := parseTimeFormat(timeFormat); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", timeFormatName, err))
+		} else {
+			options.TimeFormat = parsed
+		}
+	}
+
+	outputName := envName(prefix, EnvOutput)
+	if output := os.Getenv(outputName); output != "" {
+		if writer, err := openOutput(output); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", outputName, err))
+		} else {
+			options.Writer = writer
+		}
+	}
+
+	destinationsName := envName(prefix, EnvDestinations)
+	if destinations := os.Getenv(destinationsName); destinations != "" {
+		for _, destination := range strings.Split(destinations, ",") {
+			output, level, _ := strings.Cut(strings.TrimSpace(destination), "=")
+			if level != "" {
+				if _, err := pino.ParseLevel(level); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", destinationsName, err))
+					continue
+				}
+			}
+
+			writer, err := openOutput(output)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", destinationsName, err))
+				continue
+			}
+			options.Destinations = append(options.Destinations, Destination{Writer: writer, Level: level})
+		}
+	}
+
+	baseName := envName(prefix, EnvBase)
+	if base := os.Getenv(baseName); base != "" {
+		options.Base = map[string]interface{}{}
+		for _, field := range strings.Split(base, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok || key == "" {
+				errs = append(errs, fmt.Errorf("%s: field %s is not a key=value pair", baseName, field))
+				continue
+			}
+			options.Base[key] = value
+		}
+	}
+
+	options.TimeKey = os.Getenv(envName(prefix, EnvTimeKey))
+	options.PidKey = os.Getenv(envName(prefix, EnvPidKey))
+	options.HostnameKey = os.Getenv(envName(prefix, EnvHostnameKey))
+	options.LabelKey = os.Getenv(envName(prefix, EnvLabelKey))
+
+	levelFormatName := envName(prefix, EnvLevelFormat)
+	if levelFormat := os.Getenv(levelFormatName); levelFormat != "" {
+		if parsed, err := parseLevelFormat(levelFormat); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", levelFormatName, err))
+		} else {
+			options.LevelFormat = parsed
+		}
+	}
+
+	boolOptions := []struct {
+		name  string
+		value *bool
+	}{
+		{name: EnvOmitPid, value: &options.OmitPid},
+		{name: EnvOmitHostname, value: &options.OmitHostname},
+		{name: EnvLevelSignals, value: &options.LevelSignals},
+		{name: EnvErrorStack, value: &options.ErrorStack},
+	}
+	for _, option := range boolOptions {
+		name := envName(prefix, option.name)
+		if value := os.Getenv(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			*option.value = parsed
+		}
+	}
+
+	if len(errs) > 0 {
+		closeEnvFiles(options)
+		return InitOptions{}, errors.Join(errs...)
+	}
+
+	return options, nil
+}
+
+// closeEnvFiles closes the files opened for the writers of the options
+func closeEnvFiles(options InitOptions) {
+	writers := []io.Writer{options.Writer}
+	for _, destination := range options.Destinations {
+		writers = append(writers, destination.Writer)
+	}
+
+	for _, writer := range writers {
+		if file, ok := writer.(*os.File); ok && file != os.Stdout && file != os.Stderr {
+			_ = file.Close()
+		}
+	}
+}
+
+func envName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(prefix, "_") + "_" + name
+}
+
+func openOutput(output string) (io.Writer, error) {
+	switch strings.ToLower(output) {
+	case outputStdout:
+		return os.Stdout, nil
+	case outputStderr:
+		return os.Stderr, nil
+	default:
+		return os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+func TestInitFromEnv(t *testing.T) {
+	t.Run("Initialize a Logger without environment variables", func(t *testing.T) {
+		logger, err := InitFromEnv("ZEROPINO_TEST")
+
+		verifyInit(t, logger, err, zerolog.InfoLevel)
+	})
+
+	t.Run("Initialize a Logger from prefixed environment variables", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "service.log")
+		t.Setenv("ZEROPINO_TEST_LOG_LEVEL", "WARN")
+		t.Setenv("ZEROPINO_TEST_LOG_TIME_FORMAT", "s")
+		t.Setenv("ZEROPINO_TEST_LOG_OUTPUT", output)
+
+		logger, err := InitFromEnv("ZEROPINO_TEST_")
+		verifyInit(t, logger, err, zerolog.WarnLevel)
+
+		logger.Warn().Msg(message)
+
+		content, err := os.ReadFile(output)
+		require.NoError(t, err)
+
+		result := miaLog{}
+		require.Nil(t, json.Unmarshal(content, &result), "No error raised")
+		verifyLog(t, &result, message, string(pino.Warn), unixTimestampLen)
+	})
+
+	t.Run("Initialize a Logger from environment variables without prefix", func(t *testing.T) {
+		t.Setenv(EnvLevel, "debug")
+		t.Setenv(EnvTimeFormat, "RFC3339Nano")
+		t.Setenv(EnvOutput, "stderr")
+
+		options, err := optionsFromEnv("")
+		require.NoError(t, err)
+		require.Equal(t, InitOptions{Level: "debug", TimeFormat: TimeFormatRFC3339Nano, Writer: os.Stderr}, options)
+	})
+
+	t.Run("Initialize a Logger with destinations and fields from environment variables", func(t *testing.T) {
+		dir := t.TempDir()
+		output, errorsOutput := filepath.Join(dir, "service.log"), filepath.Join(dir, "errors.log")
+		t.Setenv("ZEROPINO_TEST_LOG_DESTINATIONS", output+"=info, "+errorsOutput+"=error")
+		t.Setenv("ZEROPINO_TEST_LOG_BASE", "service=shire,env=production")
+		t.Setenv("ZEROPINO_TEST_LOG_PID_KEY", "processId")
+		t.Setenv("ZEROPINO_TEST_LOG_HOSTNAME_KEY", "pod")
+		t.Setenv("ZEROPINO_TEST_LOG_LEVEL_FORMAT", "number+label")
+		t.Setenv("ZEROPINO_TEST_LOG_LABEL_KEY", "severity")
+
+		logger, stop, err := InitFromEnvWithStop("ZEROPINO_TEST")
+		require.NoError(t, err)
+
+		logger.Info().Msg(message)
+		logger.Error().Msg(message)
+		stop()
+
+		content, err := os.ReadFile(output)
+		require.NoError(t, err)
+		require.Len(t, strings.Split(strings.TrimSpace(string(content)), "\n"), 2)
+
+		content, err = os.ReadFile(errorsOutput)
+		require.NoError(t, err)
+
+		result := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(content, &result), "No error raised")
+		require.Equal(t, float64(50), result["level"])
+		require.Equal(t, "error", result["severity"])
+		require.Equal(t, "shire", result["service"])
+		require.Equal(t, "production", result["env"])
+		require.Contains(t, result, "processId")
+		require.Contains(t, result, "pod")
+
+		options, err := optionsFromEnv("ZEROPINO_TEST")
+		require.NoError(t, err)
+		file := options.Destinations[1].Writer.(*os.File)
+		closeEnvFiles(options)
+		require.ErrorIs(t, file.Close(), os.ErrClosed, "Opened files are closed")
+	})
+
+	t.Run("Initialize a Logger with keys and flags from environment variables", func(t *testing.T) {
+		t.Setenv("ZEROPINO_TEST_LOG_TIME_KEY", "timestamp")
+		t.Setenv("ZEROPINO_TEST_LOG_OMIT_PID", "true")
+		t.Setenv("ZEROPINO_TEST_LOG_OMIT_HOSTNAME", "1")
+		t.Setenv("ZEROPINO_TEST_LOG_LEVEL_SIGNALS", "TRUE")
+		t.Setenv("ZEROPINO_TEST_LOG_ERROR_STACK", "false")
+
+		options, err := optionsFromEnv("ZEROPINO_TEST")
+		require.NoError(t, err)
+		require.Equal(t, InitOptions{TimeKey: "timestamp", OmitPid: true, OmitHostname: true, LevelSignals: true}, options)
+	})
+
+	t.Run("Report every invalid environment variable", func(t *testing.T) {
+		t.Setenv("ZEROPINO_TEST_LOG_LEVEL", "verbose")
+		t.Setenv("ZEROPINO_TEST_LOG_TIME_FORMAT", "iso")
+		t.Setenv("ZEROPINO_TEST_LOG_OUTPUT", filepath.Join(t.TempDir(), "missing", "service.log"))
+		t.Setenv("ZEROPINO_TEST_LOG_DESTINATIONS", "stdout=verbose")
+		t.Setenv("ZEROPINO_TEST_LOG_BASE", "service")
+		t.Setenv("ZEROPINO_TEST_LOG_LEVEL_FORMAT", "emoji")
+		t.Setenv("ZEROPINO_TEST_LOG_OMIT_PID", "maybe")
+
+		logger, err := InitFromEnv("ZEROPINO_TEST")
+
+		var emptyPointer *zerolog.Logger
+		require.Equal(t, emptyPointer, logger)
+		require.Error(t, err)
+
+		lines := strings.Split(err.Error(), "\n")
+		require.Len(t, lines, 7)
+		require.Equal(t, "ZEROPINO_TEST_LOG_LEVEL: level verbose is not recognized", lines[0])
+		require.Equal(t, "ZEROPINO_TEST_LOG_TIME_FORMAT: time format iso is not recognized", lines[1])
+		require.True(t, strings.HasPrefix(lines[2], "ZEROPINO_TEST_LOG_OUTPUT: "))
+		require.Equal(t, "ZEROPINO_TEST_LOG_DESTINATIONS: level verbose is not recognized", lines[3])
+		require.Equal(t, "ZEROPINO_TEST_LOG_BASE: field service is not a key=value pair", lines[4])
+		require.Equal(t, "ZEROPINO_TEST_LOG_LEVEL_FORMAT: level format emoji is not recognized", lines[5])
+		require.Equal(t, `ZEROPINO_TEST_LOG_OMIT_PID: strconv.ParseBool: parsing "maybe": invalid syntax`, lines[6])
+	})
+}
@@ -0,0 +1,148 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrorKey is the key pino uses for serialized errors
+const ErrorKey = "err"
+
+// maxErrorDepth limits the causes serialized for each error, preventing endless chains
+const maxErrorDepth = 16
+
+// loggingFunctionPrefixes identify the frames skipped when capturing the stack of the code logging an error
+var loggingFunctionPrefixes = []string{
+	"github.com/danibix95/zeropino.errorObject.",
+	"github.com/danibix95/zeropino.appendCallerStack",
+	"github.com/danibix95/zeropino.(*pinoWriter).",
+	"github.com/danibix95/zeropino.(*format).",
+	"github.com/danibix95/zeropino.stdLogWriter.",
+	"github.com/danibix95/zeropino.(*SlogHandler).",
+	"github.com/rs/zerolog.",
+	"log.",
+	"log/slog.",
+}
+
+// errorObject serializes an error as pino err serializer does
+type errorObject struct {
+	err   error
+	depth int
+	// callerStack writes the stack of the code logging the error when err does not provide one
+	callerStack bool
+}
+
+// ErrorObject wraps err so that it is logged as pino does, i.e. as an object with
+// type, message and stack fields, e.g. logger.Error().Object(ErrorKey, ErrorObject(err)).
+// The type is the Go type of the error, while its causes, obtained through errors.Unwrap
+// or the Unwrap() []error method of errors.Join, are nested under cause or aggregateErrors keys.
+// The stack is the one formatted by the error with %+v verb, when it provides one,
+// otherwise it is the stack of the code logging the error, captured only when the event is enabled.
+func ErrorObject(err error) zerolog.LogObjectMarshaler {
+	if err == nil {
+		return nil
+	}
+	return errorObject{err: err, callerStack: true}
+}
+
+// MarshalZerologObject implements zerolog.LogObjectMarshaler interface
+func (o errorObject) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", fmt.Sprintf("%T", o.err)).
+		Str("message", o.err.Error())
+	if o.depth == 0 {
+		stack := formattedStack(o.err)
+		if stack == "" && o.callerStack {
+			stack = callerStack()
+		}
+		if stack != "" {
+			e.Str("stack", stack)
+		}
+	}
+
+	if o.depth >= maxErrorDepth {
+		return
+	}
+
+	switch unwrapper := o.err.(type) {
+	case interface{ Unwrap() []error }:
+		causes := zerolog.Arr()
+		for _, cause := range unwrapper.Unwrap() {
+			if cause != nil {
+				causes.Object(errorObject{err: cause, depth: o.depth + 1})
+			}
+		}
+		e.Array("aggregateErrors", causes)
+	default:
+		if cause := errors.Unwrap(o.err); cause != nil {
+			e.Object("cause", errorObject{err: cause, depth: o.depth + 1})
+		}
+	}
+}
+
+// formattedStack returns the stack formatted by err with %+v verb, if any
+func formattedStack(err error) string {
+	if _, ok := err.(fmt.Formatter); ok {
+		if verbose := fmt.Sprintf("%+v", err); verbose != err.Error() {
+			return verbose
+		}
+	}
+	return ""
+}
+
+// callerStack formats the stack of the code logging an error as panics do,
+// skipping the frames of zeropino, zerolog and the standard logging packages
+func callerStack() string {
+	pc := make([]uintptr, 32)
+	// skip runtime.Callers and callerStack frames
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	var stack strings.Builder
+	skipping := true
+	for {
+		frame, more := frames.Next()
+		if skipping && isLoggingFrame(frame.Function) {
+			if !more {
+				break
+			}
+			continue
+		}
+		skipping = false
+
+		fmt.Fprintf(&stack, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+
+	return strings.TrimSuffix(stack.String(), "\n")
+}
+
+func isLoggingFrame(function string) bool {
+	for _, prefix := range loggingFunctionPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
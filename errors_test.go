@@ -0,0 +1,106 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type verboseError struct{}
+
+func (verboseError) Error() string { return "verbose failure" }
+
+func (e verboseError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		_, _ = fmt.Fprint(s, "verbose failure\n\tat main.go:42")
+		return
+	}
+	_, _ = fmt.Fprint(s, e.Error())
+}
+
+func TestErrorObject(t *testing.T) {
+	t.Run("Log an error with its cause chain", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out})
+		require.NoError(t, err)
+
+		_, openErr := os.Open("/missing/mordor.txt")
+		logger.Error().Object(ErrorKey, ErrorObject(fmt.Errorf("reading map: %w", openErr))).Msg(message)
+
+		result := struct {
+			Err map[string]interface{} `json:"err"`
+		}{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+
+		require.Equal(t, "*fmt.wrapError", result.Err["type"])
+		require.Equal(t, "reading map: open /missing/mordor.txt: no such file or directory", result.Err["message"])
+		require.True(t, strings.HasPrefix(result.Err["stack"].(string), "github.com/danibix95/zeropino.TestErrorObject.func1\n"),
+			"Stack starts from the caller of ErrorObject")
+
+		cause := result.Err["cause"].(map[string]interface{})
+		require.Equal(t, fmt.Sprintf("%T", &fs.PathError{}), cause["type"])
+		require.NotContains(t, cause, "stack", "Only the logged error has a stack")
+		require.Equal(t, "syscall.Errno", cause["cause"].(map[string]interface{})["type"])
+	})
+
+	t.Run("Log joined errors", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out})
+		require.NoError(t, err)
+
+		joined := errors.Join(errors.New("first"), verboseError{})
+		logger.Error().Object(ErrorKey, ErrorObject(joined)).Msg(message)
+
+		result := struct {
+			Err struct {
+				Type            string                   `json:"type"`
+				Message         string                   `json:"message"`
+				AggregateErrors []map[string]interface{} `json:"aggregateErrors"`
+			} `json:"err"`
+		}{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+
+		require.Equal(t, "*errors.joinError", result.Err.Type)
+		require.Equal(t, "first\nverbose failure", result.Err.Message)
+		require.Len(t, result.Err.AggregateErrors, 2)
+		require.Equal(t, "*errors.errorString", result.Err.AggregateErrors[0]["type"])
+		require.Equal(t, "zeropino.verboseError", result.Err.AggregateErrors[1]["type"])
+	})
+
+	t.Run("Log the stack provided by the error", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out})
+		require.NoError(t, err)
+
+		logger.Error().Object(ErrorKey, ErrorObject(verboseError{})).Msg(message)
+
+		result := struct {
+			Err map[string]interface{} `json:"err"`
+		}{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		require.Equal(t, "verbose failure\n\tat main.go:42", result.Err["stack"])
+	})
+}
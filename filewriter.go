@@ -0,0 +1,361 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+	defaultFileMode  = 0o600
+	defaultFileFlags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+)
+
+var errFilenameRequired = errors.New("filename is required")
+
+// FileOptions are the possible options that can be used to create a FileWriter
+type FileOptions struct {
+	// Filename is the path of the file logs are written to
+	Filename string
+	// MaxSize is the size in bytes the file can reach before being rotated.
+	// Zero disables size based rotation.
+	MaxSize int64
+	// MaxAge is the time the file is written for before being rotated, counted since
+	// the FileWriter opened it: a file appended again after a restart of the program
+	// is kept for a whole MaxAge, whatever its age. Zero disables age based rotation.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files to keep. Zero keeps all of them.
+	MaxBackups int
+	// Compress selects whether rotated files are compressed with gzip
+	Compress bool
+	// ReopenOnSIGHUP selects whether the file is reopened when the process receives
+	// a SIGHUP signal, as expected by logrotate. It is available on Unix only.
+	// Once the FileWriter is closed, SIGHUP is handled again as before, which
+	// terminates the process unless the program handles or ignores it elsewhere.
+	ReopenOnSIGHUP bool
+}
+
+// FileWriter is an io.Writer that writes logs to a file, rotating it by size and age.
+// It is safe for concurrent use, so a single FileWriter can be shared by all the
+// per-request loggers created from the same logger.
+type FileWriter struct {
+	options FileOptions
+	now     func() time.Time
+
+	mu sync.Mutex
+	// file is nil when the FileWriter is closed or when it could not be opened
+	// again after a rotation, in which case the following write opens it
+	file     *os.File
+	closed   bool
+	size     int64
+	openedAt time.Time
+
+	// rotated files are compressed and removed in background, one rotation at a time
+	millMu sync.Mutex
+	millWg sync.WaitGroup
+
+	stopSignals func()
+}
+
+// NewFileWriter creates a FileWriter, opening the file in append mode
+func NewFileWriter(options FileOptions) (*FileWriter, error) {
+	if options.Filename == "" {
+		return nil, errFilenameRequired
+	}
+
+	w := &FileWriter{options: options, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	if options.ReopenOnSIGHUP {
+		stop, err := watchReopenSignal(w)
+		if err != nil {
+			_ = w.file.Close()
+			return nil, err
+		}
+		w.stopSignals = stop
+	}
+
+	return w, nil
+}
+
+// Write implements io.Writer interface, rotating the file beforehand when needed.
+// When the rotation fails, the log is written to the current file and the rotation
+// is tried again by the following write.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.ensureOpen(); err != nil {
+		return 0, err
+	}
+
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil && w.file == nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current file to a backup and opens a new one
+func (w *FileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.ensureOpen(); err != nil {
+		return err
+	}
+	return w.rotate()
+}
+
+// Reopen closes and opens again the file, so that logs are written to a new file
+// when the current one has been moved by an external tool such as logrotate
+func (w *FileWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file != nil {
+		err := w.file.Close()
+		w.file = nil
+		if err != nil {
+			return err
+		}
+	}
+	return w.open()
+}
+
+// Close stops watching signals, waits for rotated files to be processed and closes the file.
+// Stopping restores the default behavior of SIGHUP, which terminates the process.
+func (w *FileWriter) Close() error {
+	if w.stopSignals != nil {
+		w.stopSignals()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.millWg.Wait()
+
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// ensureOpen opens the file again when a previous rotation could not
+func (w *FileWriter) ensureOpen() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file == nil {
+		return w.open()
+	}
+	return nil
+}
+
+func (w *FileWriter) shouldRotate(writeSize int64) bool {
+	if w.options.MaxSize > 0 && w.size > 0 && w.size+writeSize > w.options.MaxSize {
+		return true
+	}
+	return w.options.MaxAge > 0 && w.now().Sub(w.openedAt) >= w.options.MaxAge
+}
+
+func (w *FileWriter) open() error {
+	file, err := os.OpenFile(w.options.Filename, defaultFileFlags, defaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = w.now()
+	return nil
+}
+
+// rotate moves the file to a backup and opens a new one. On failure, the original
+// file is opened again, if possible, otherwise the file is left nil to be opened
+// by the following write.
+func (w *FileWriter) rotate() error {
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return err
+	}
+
+	backup := w.nextBackupName()
+	if err := os.Rename(w.options.Filename, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		if openErr := w.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.millWg.Add(1)
+	go func() {
+		defer w.millWg.Done()
+		w.mill(backup)
+	}()
+
+	return nil
+}
+
+// backupName returns the name of a rotated file, e.g. /var/log/app-2021-08-18T10-30-00.000.log
+func (w *FileWriter) backupName(t time.Time) string {
+	dir, prefix, ext := w.nameParts()
+	return filepath.Join(dir, fmt.Sprintf("%s%s%s", prefix, t.Format(backupTimeFormat), ext))
+}
+
+// nextBackupName returns a backup name not used yet, since many rotations
+// may happen within the same millisecond
+func (w *FileWriter) nextBackupName() string {
+	t := w.now()
+	for {
+		backup := w.backupName(t)
+		if !fileExists(backup) && !fileExists(backup+compressSuffix) {
+			return backup
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func (w *FileWriter) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(w.options.Filename)
+	base := filepath.Base(w.options.Filename)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// mill compresses the backup, when requested, and removes the exceeding backups.
+// Errors are ignored, since there is no place they could be reported to.
+func (w *FileWriter) mill(backup string) {
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	if w.options.Compress {
+		if err := compressFile(backup); err == nil {
+			_ = os.Remove(backup)
+		}
+	}
+
+	if w.options.MaxBackups > 0 {
+		backups := w.backups()
+		for i := w.options.MaxBackups; i < len(backups); i++ {
+			_ = os.Remove(backups[i])
+		}
+	}
+}
+
+// backups lists the rotated files, from the newest to the oldest
+func (w *FileWriter) backups() []string {
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	type backup struct {
+		path string
+		time time.Time
+	}
+
+	var found []backup
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), compressSuffix)
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		t, err := time.Parse(backupTimeFormat, timestamp)
+		if err != nil {
+			continue
+		}
+		found = append(found, backup{path: filepath.Join(dir, entry.Name()), time: t})
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].time.After(found[j].time)
+	})
+
+	paths := make([]string, 0, len(found))
+	for _, b := range found {
+		paths = append(paths, b.path)
+	}
+	return paths
+}
+
+func compressFile(source string) (err error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(source+compressSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, defaultFileMode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,241 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const logLine = "{\"level\":\"30\",\"msg\":\"rotate me\"}\n"
+
+func TestFileWriter(t *testing.T) {
+	t.Run("Create a FileWriter without filename", func(t *testing.T) {
+		writer, err := NewFileWriter(FileOptions{})
+
+		require.ErrorIs(t, err, errFilenameRequired)
+		require.Nil(t, writer)
+	})
+
+	t.Run("Rotate file by size keeping the latest backups", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "service.log")
+		writer, err := NewFileWriter(FileOptions{
+			Filename:   filename,
+			MaxSize:    int64(2 * len(logLine)),
+			MaxBackups: 2,
+		})
+		require.NoError(t, err)
+		clock := fakeClock(writer)
+
+		for i := 0; i < 7; i++ {
+			clock.Add(time.Second)
+			_, err = writer.Write([]byte(logLine))
+			require.NoError(t, err)
+		}
+		require.NoError(t, writer.Close())
+
+		requireFileContent(t, filename, logLine)
+		backups := writer.backups()
+		require.Len(t, backups, 2, "Only the latest backups are kept")
+		for _, backup := range backups {
+			requireFileContent(t, backup, logLine+logLine)
+		}
+	})
+
+	t.Run("Rotate file by age compressing the backups", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "service.log")
+		writer, err := NewFileWriter(FileOptions{
+			Filename: filename,
+			MaxAge:   time.Hour,
+			Compress: true,
+		})
+		require.NoError(t, err)
+		clock := fakeClock(writer)
+
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err)
+
+		clock.Add(30 * time.Minute)
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err)
+
+		clock.Add(30 * time.Minute)
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		requireFileContent(t, filename, logLine)
+		backups := writer.backups()
+		require.Len(t, backups, 1)
+		require.True(t, strings.HasSuffix(backups[0], compressSuffix), "Backup is compressed")
+
+		file, err := os.Open(backups[0])
+		require.NoError(t, err)
+		defer file.Close()
+		reader, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, logLine+logLine, string(content))
+	})
+
+	t.Run("Reopen file moved by an external tool", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "service.log")
+		writer, err := NewFileWriter(FileOptions{Filename: filename})
+		require.NoError(t, err)
+
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err)
+		require.NoError(t, os.Rename(filename, filename+".1"))
+
+		require.NoError(t, writer.Reopen())
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		requireFileContent(t, filename, logLine)
+		requireFileContent(t, filename+".1", logLine)
+
+		_, err = writer.Write([]byte(logLine))
+		require.ErrorIs(t, err, os.ErrClosed, "Closed writer does not accept writes")
+	})
+
+	t.Run("Keep writing when the file cannot be rotated", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("directory permissions do not apply to root")
+		}
+
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "service.log")
+		writer, err := NewFileWriter(FileOptions{Filename: filename, MaxSize: int64(len(logLine))})
+		require.NoError(t, err)
+
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err)
+
+		require.NoError(t, os.Chmod(dir, 0o500))
+		t.Cleanup(func() { _ = os.Chmod(dir, 0o700) })
+
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err, "The log is written to the current file")
+		require.Error(t, writer.Rotate())
+
+		require.NoError(t, os.Chmod(dir, 0o700))
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		requireFileContent(t, filename, logLine)
+		backups := writer.backups()
+		require.Len(t, backups, 1, "The rotation is tried again")
+		requireFileContent(t, backups[0], logLine+logLine)
+	})
+
+	t.Run("Open the file again after a failed rotation", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "service.log")
+		writer, err := NewFileWriter(FileOptions{Filename: filename})
+		require.NoError(t, err)
+
+		writer.mu.Lock()
+		require.NoError(t, writer.file.Close())
+		writer.file = nil
+		writer.mu.Unlock()
+
+		_, err = writer.Write([]byte(logLine))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		requireFileContent(t, filename, logLine)
+	})
+
+	t.Run("Concurrent writes from request loggers", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "service.log")
+		writer, err := NewFileWriter(FileOptions{Filename: filename, MaxSize: 4096})
+		require.NoError(t, err)
+
+		logger, err := Init(InitOptions{Writer: writer})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				reqLogger := logger.With().Str("reqId", "my-id").Logger()
+				for j := 0; j < 20; j++ {
+					reqLogger.Info().Msg(message)
+				}
+			}()
+		}
+		wg.Wait()
+		require.NoError(t, writer.Close())
+
+		lines := 0
+		for _, name := range append(writer.backups(), filename) {
+			content, err := os.ReadFile(name)
+			require.NoError(t, err)
+			for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+				require.Contains(t, line, message, "Lines are not interleaved")
+				lines++
+			}
+		}
+		require.Equal(t, 400, lines)
+	})
+}
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeClock replaces the writer clock, restarting its age from the returned clock time
+func fakeClock(w *FileWriter) *clock {
+	c := &clock{now: time.Date(2021, 8, 18, 10, 30, 0, 0, time.UTC)}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.now = c.Now
+	w.openedAt = c.Now()
+	return c
+}
+
+func requireFileContent(t testing.TB, filename, expected string) {
+	t.Helper()
+
+	content, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(content))
+}
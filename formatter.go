@@ -0,0 +1,418 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+const (
+	defaultLevelKey    = "level"
+	defaultMessageKey  = "msg"
+	defaultTimeKey     = "time"
+	defaultPidKey      = "pid"
+	defaultHostnameKey = "hostname"
+	defaultLabelKey    = "label"
+)
+
+// LevelFormat selects how the level of each log is written
+type LevelFormat int
+
+const (
+	// LevelFormatString writes the pino level number as a string, e.g. "level":"30"
+	LevelFormatString LevelFormat = iota
+	// LevelFormatNumber writes the pino level number, e.g. "level":30, as pino does
+	LevelFormatNumber
+	// LevelFormatLabel writes the level name, e.g. "level":"info", as pino formatters.level allows
+	LevelFormatLabel
+	// LevelFormatNumberAndLabel writes the pino level number together with
+	// the level name under a separate key, e.g. "level":30,"label":"info"
+	LevelFormatNumberAndLabel
+)
+
+// parseLevelFormat returns the level format matching the given name, ignoring its case
+func parseLevelFormat(name string) (LevelFormat, error) {
+	switch strings.ToLower(name) {
+	case "", "string":
+		return LevelFormatString, nil
+	case "number":
+		return LevelFormatNumber, nil
+	case "label":
+		return LevelFormatLabel, nil
+	case "number+label":
+		return LevelFormatNumberAndLabel, nil
+	default:
+		return LevelFormatString, fmt.Errorf("level format %s is not recognized", name)
+	}
+}
+
+// format collects the pino formatting settings owned by a single logger.
+// Zerolog keeps these settings in package globals, therefore zeropino never
+// changes them and rewrites instead each event produced by the logger
+type format struct {
+	levelKey       string
+	messageKey     string
+	timeKey        string
+	timeFormat     TimeFormat
+	levelFormat    LevelFormat
+	labelKey       string
+	levelMarshaler func(zerolog.Level) string
+	redactor       *redactor
+	// errorStack adds the stack of the code logging an error next to the error field
+	errorStack bool
+	// base fields added to every event, where an empty key omits the field
+	pidKey      string
+	hostnameKey string
+	base        map[string]interface{}
+}
+
+func defaultFormat() format {
+	return format{
+		levelKey:       defaultLevelKey,
+		messageKey:     defaultMessageKey,
+		timeKey:        defaultTimeKey,
+		timeFormat:     TimeFormatEpochMs,
+		labelKey:       defaultLabelKey,
+		levelMarshaler: pino.ConvertLevel,
+		pidKey:         defaultPidKey,
+		hostnameKey:    defaultHostnameKey,
+	}
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, 0, 500)
+		return &buffer
+	},
+}
+
+// pinoWriter converts the level and message fields written by zerolog
+// into the ones defined by the logger format before forwarding the event
+type pinoWriter struct {
+	out    zerolog.LevelWriter
+	format format
+}
+
+func newPinoWriter(w io.Writer, f format) *pinoWriter {
+	return &pinoWriter{out: levelWriter(w), format: f}
+}
+
+// Write implements io.Writer interface
+func (w *pinoWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter interface
+func (w *pinoWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	var zerologScratch, formatScratch [64]byte
+	prefixes := w.format.levelPrefixes(zerologScratch[:0], formatScratch[:0], level)
+	if w.format.redactor == nil && !w.format.rewrites(prefixes, p) {
+		return w.out.WriteLevel(level, p)
+	}
+
+	buffer := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buffer)
+
+	*buffer = w.format.appendEvent((*buffer)[:0], prefixes, p)
+	event := *buffer
+
+	if w.format.redactor != nil {
+		redacted := bufferPool.Get().(*[]byte)
+		defer bufferPool.Put(redacted)
+
+		*redacted = w.format.redactor.appendRedacted((*redacted)[:0], event)
+		event = *redacted
+	}
+
+	if _, err := w.out.WriteLevel(level, event); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// levelPrefixes are the beginnings of an event up to its level fields,
+// as written by zerolog and as required by the format
+type levelPrefixes struct {
+	zerolog []byte
+	format  []byte
+}
+
+// levelPrefixes appends the beginnings of an event of the given level to the two buffers,
+// so that they are computed once for each event
+func (f *format) levelPrefixes(zerologDst, formatDst []byte, level zerolog.Level) levelPrefixes {
+	return levelPrefixes{zerolog: zerologLevelPrefix(zerologDst, level), format: f.levelPrefix(formatDst, level)}
+}
+
+// rewrites reports whether the event p may need to be changed to match the format,
+// so that events already matching it are written without being copied
+func (f *format) rewrites(prefixes levelPrefixes, p []byte) bool {
+	if len(p) == 0 || p[0] != '{' {
+		return false
+	}
+
+	if !bytes.Equal(prefixes.zerolog, prefixes.format) {
+		return true
+	}
+
+	var scratch [64]byte
+	if zerolog.MessageFieldName != f.messageKey && bytes.Contains(p, appendKey(scratch[:0], f.messageKey)) {
+		return true
+	}
+	return f.errorStack && bytes.Contains(p, appendKey(scratch[:0], zerolog.ErrorFieldName))
+}
+
+// appendEvent appends to dst the event p, whose level, message and error fields
+// are encoded according to the format instead of zerolog global settings
+func (f *format) appendEvent(dst []byte, prefixes levelPrefixes, p []byte) []byte {
+	if len(p) == 0 || p[0] != '{' {
+		return append(dst, p...)
+	}
+
+	// zerolog always writes the level as first field of the event
+	fields := p[1:]
+	if len(prefixes.zerolog) > 1 && bytes.HasPrefix(p, prefixes.zerolog) {
+		fields = p[len(prefixes.zerolog):]
+		if len(fields) > 0 && fields[0] == ',' {
+			fields = fields[1:]
+		}
+	}
+
+	dst = append(dst, prefixes.format...)
+	if len(dst) > 1 && len(fields) > 0 && fields[0] != '}' {
+		dst = append(dst, ',')
+	}
+
+	// the message written by zerolog duplicates the one written by messageHook
+	if start, end := f.zerologMessage(fields); start >= 0 {
+		dst = f.appendFields(dst, fields[:start])
+		return append(dst, fields[end:]...)
+	}
+	return f.appendFields(dst, fields)
+}
+
+// appendFields appends the fields of an event, adding the stack of the code logging it
+// after the error field written by zerolog when required
+func (f *format) appendFields(dst, fields []byte) []byte {
+	if !f.errorStack {
+		return append(dst, fields...)
+	}
+
+	_, _, errorEnd, ok := findField(fields, zerolog.ErrorFieldName)
+	if !ok {
+		return append(dst, fields...)
+	}
+
+	if _, _, _, ok := findField(fields, zerolog.ErrorStackFieldName); ok {
+		return append(dst, fields...)
+	}
+	dst = append(dst, fields[:errorEnd]...)
+	dst = appendCallerStack(append(dst, ','))
+	return append(dst, fields[errorEnd:]...)
+}
+
+// appendCallerStack appends the stack field with the stack of the code logging the event
+func appendCallerStack(dst []byte) []byte {
+	// a string is always encoded without errors
+	stack, _ := json.Marshal(callerStack())
+	return append(appendKey(dst, zerolog.ErrorStackFieldName), stack...)
+}
+
+// zerologLevelPrefix appends the beginning of an event written by zerolog, up to its level field
+func zerologLevelPrefix(dst []byte, level zerolog.Level) []byte {
+	if level == zerolog.NoLevel || zerolog.LevelFieldName == "" {
+		return append(dst, '{')
+	}
+
+	dst = append(dst, '{', '"')
+	dst = append(dst, zerolog.LevelFieldName...)
+	dst = append(dst, '"', ':', '"')
+	dst = append(dst, zerolog.LevelFieldMarshalFunc(level)...)
+	return append(dst, '"')
+}
+
+// levelPrefix appends the beginning of an event written according to the format, up to its level fields
+func (f *format) levelPrefix(dst []byte, level zerolog.Level) []byte {
+	dst = append(dst, '{')
+	if value := f.levelMarshaler(level); value != "" && level != zerolog.NoLevel {
+		dst = f.appendLevel(dst, level, value)
+	}
+	return dst
+}
+
+// appendLevel appends the level fields, where value is the pino level number
+func (f *format) appendLevel(dst []byte, level zerolog.Level, value string) []byte {
+	switch f.levelFormat {
+	case LevelFormatNumber:
+		return append(appendKey(dst, f.levelKey), value...)
+	case LevelFormatLabel:
+		return appendString(appendKey(dst, f.levelKey), pino.LevelName(level))
+	case LevelFormatNumberAndLabel:
+		dst = append(append(appendKey(dst, f.levelKey), value...), ',')
+		return appendString(appendKey(dst, f.labelKey), pino.LevelName(level))
+	default:
+		return appendString(appendKey(dst, f.levelKey), value)
+	}
+}
+
+// zerologMessage returns the bounds of the message field, together with its preceding comma,
+// that zerolog writes at the end of the fields, or -1 when there is none. Since zerolog writes the message
+// only when it is not empty, the last field is considered the message only when it repeats
+// the one written by messageHook, so that a field sharing its key is never mistaken for it.
+func (f *format) zerologMessage(fields []byte) (int, int) {
+	if zerolog.MessageFieldName == f.messageKey {
+		return -1, -1
+	}
+
+	valueStart, valueEnd := lastString(fields)
+	if valueStart < 0 {
+		return -1, -1
+	}
+	value := fields[valueStart:valueEnd]
+
+	var scratch [64]byte
+	key := appendKey(append(scratch[:0], ','), zerolog.MessageFieldName)
+	if !bytes.HasSuffix(fields[:valueStart], key) {
+		return -1, -1
+	}
+	index := valueStart - len(key)
+
+	// messageHook is the last hook of the logger, so its field usually precedes the zerolog message,
+	// while hooks added afterwards write their fields in between
+	var hookScratch [64]byte
+	hookKey := appendKey(hookScratch[:0], f.messageKey)
+	if before := fields[:index]; bytes.HasSuffix(before, value) && bytes.HasSuffix(before[:len(before)-len(value)], hookKey) {
+		return index, valueEnd
+	}
+	for search := fields[:index]; ; {
+		position := bytes.LastIndex(search, hookKey)
+		if position < 0 {
+			return -1, -1
+		}
+		if candidate := fields[position+len(hookKey) : index+1]; bytes.HasPrefix(candidate, value) && len(candidate) > len(value) && candidate[len(value)] == ',' {
+			return index, valueEnd
+		}
+		search = search[:position]
+	}
+}
+
+// lastString returns the bounds of the JSON string closing the event, including its quotes,
+// or -1 when the event does not end with a string
+func lastString(fields []byte) (int, int) {
+	end := len(bytes.TrimRight(fields, "\n"))
+	if end < 2 || fields[end-1] != '}' || fields[end-2] != '"' {
+		return -1, -1
+	}
+	end--
+
+	// strings cannot contain unescaped quotes, so the first one found backwards opens the string
+	for i := end - 2; i >= 0; i-- {
+		if fields[i] != '"' {
+			continue
+		}
+		backslashes := 0
+		for j := i - 1; j >= 0 && fields[j] == '\\'; j-- {
+			backslashes++
+		}
+		if backslashes%2 == 0 {
+			return i, end
+		}
+	}
+	return -1, -1
+}
+
+// findField returns the index of the key, the value and the end of the top level field
+// with the given key among the fields of an event, reporting whether it has been found
+func findField(fields []byte, key string) (int, int, int, bool) {
+	for i := skipSpaces(fields, 0); i < len(fields) && fields[i] != '}'; {
+		keyEnd, err := skipString(fields, i)
+		if err != nil {
+			return 0, 0, 0, false
+		}
+
+		valueStart := skipSpaces(fields, keyEnd)
+		if valueStart >= len(fields) || fields[valueStart] != ':' {
+			return 0, 0, 0, false
+		}
+		valueStart = skipSpaces(fields, valueStart+1)
+		valueEnd, err := skipValue(fields, valueStart)
+		if err != nil {
+			return 0, 0, 0, false
+		}
+
+		if string(unquoteKey(fields[i:keyEnd])) == key {
+			return i, valueStart, valueEnd, true
+		}
+
+		i = skipSpaces(fields, valueEnd)
+		if i < len(fields) && fields[i] == ',' {
+			i = skipSpaces(fields, i+1)
+		}
+	}
+
+	return 0, 0, 0, false
+}
+
+// messageHook writes the message of each event under the message key of the format,
+// since the message written by zerolog uses the key set in its package globals
+type messageHook struct {
+	key string
+}
+
+// Run implements zerolog.Hook interface
+func (h messageHook) Run(e *zerolog.Event, _ zerolog.Level, msg string) {
+	if msg != "" {
+		e.Str(h.key, msg)
+	}
+}
+
+// appendKey appends a JSON key, which is expected not to require escaping
+func appendKey(dst []byte, key string) []byte {
+	return append(appendString(dst, key), ':')
+}
+
+// appendString appends a JSON string, which is expected not to require escaping
+func appendString(dst []byte, value string) []byte {
+	dst = append(dst, '"')
+	dst = append(dst, value...)
+	return append(dst, '"')
+}
+
+type levelWriterAdapter struct {
+	io.Writer
+}
+
+// WriteLevel implements zerolog.LevelWriter interface
+func (lw levelWriterAdapter) WriteLevel(_ zerolog.Level, p []byte) (int, error) {
+	return lw.Write(p)
+}
+
+func levelWriter(w io.Writer) zerolog.LevelWriter {
+	if lw, ok := w.(zerolog.LevelWriter); ok {
+		return lw
+	}
+	return levelWriterAdapter{w}
+}
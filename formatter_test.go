@@ -0,0 +1,205 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinoWriter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		level    zerolog.Level
+		input    string
+		expected string
+	}{
+		{
+			name:     "level and message are converted",
+			level:    zerolog.InfoLevel,
+			input:    `{"level":"info","pid":1,"msg":"hello","message":"hello"}` + "\n",
+			expected: `{"level":"30","pid":1,"msg":"hello"}` + "\n",
+		},
+		{
+			name:     "event without message",
+			level:    zerolog.WarnLevel,
+			input:    `{"level":"warn","pid":1}` + "\n",
+			expected: `{"level":"40","pid":1}` + "\n",
+		},
+		{
+			name:     "event with only level",
+			level:    zerolog.ErrorLevel,
+			input:    `{"level":"error"}` + "\n",
+			expected: `{"level":"50"}` + "\n",
+		},
+		{
+			name:     "event without level",
+			level:    zerolog.NoLevel,
+			input:    `{"pid":1,"msg":"hello","message":"hello"}` + "\n",
+			expected: `{"pid":1,"msg":"hello"}` + "\n",
+		},
+		{
+			name:     "nested message key is left untouched",
+			level:    zerolog.InfoLevel,
+			input:    `{"level":"info","err":{"message":"boom"}}` + "\n",
+			expected: `{"level":"30","err":{"message":"boom"}}` + "\n",
+		},
+		{
+			name:     "nested message key before the actual message",
+			level:    zerolog.InfoLevel,
+			input:    `{"level":"info","err":{"message":"boom"},"msg":"say \"message\":\"x\"","message":"say \"message\":\"x\""}` + "\n",
+			expected: `{"level":"30","err":{"message":"boom"},"msg":"say \"message\":\"x\""}` + "\n",
+		},
+		{
+			name:     "message field of an event without message is left untouched",
+			level:    zerolog.InfoLevel,
+			input:    `{"level":"info","message":"from the user"}` + "\n",
+			expected: `{"level":"30","message":"from the user"}` + "\n",
+		},
+		{
+			name:     "message field differing from the event message is left untouched",
+			level:    zerolog.InfoLevel,
+			input:    `{"level":"info","msg":"hello","time":1,"message":"from the user"}` + "\n",
+			expected: `{"level":"30","msg":"hello","time":1,"message":"from the user"}` + "\n",
+		},
+		{
+			name:     "not a JSON object",
+			level:    zerolog.InfoLevel,
+			input:    "plain text\n",
+			expected: "plain text\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			writer := newPinoWriter(out, defaultFormat())
+
+			n, err := writer.WriteLevel(testCase.level, []byte(testCase.input))
+			require.NoError(t, err)
+			require.Equal(t, len(testCase.input), n, "Written bytes refer to the original event")
+			require.Equal(t, testCase.expected, out.String())
+		})
+	}
+}
+
+func TestPinoWriterLevelFormat(t *testing.T) {
+	testCases := []struct {
+		name        string
+		levelFormat LevelFormat
+		expected    string
+	}{
+		{
+			name:        "level as string",
+			levelFormat: LevelFormatString,
+			expected:    `{"level":"40","msg":"hello"}` + "\n",
+		},
+		{
+			name:        "level as number",
+			levelFormat: LevelFormatNumber,
+			expected:    `{"level":40,"msg":"hello"}` + "\n",
+		},
+		{
+			name:        "level as label",
+			levelFormat: LevelFormatLabel,
+			expected:    `{"level":"warn","msg":"hello"}` + "\n",
+		},
+		{
+			name:        "level as number and label",
+			levelFormat: LevelFormatNumberAndLabel,
+			expected:    `{"level":40,"label":"warn","msg":"hello"}` + "\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			logFormat := defaultFormat()
+			logFormat.levelFormat = testCase.levelFormat
+
+			out := &bytes.Buffer{}
+			writer := newPinoWriter(out, logFormat)
+
+			_, err := writer.WriteLevel(zerolog.WarnLevel, []byte(`{"level":"warn","msg":"hello","message":"hello"}`+"\n"))
+			require.NoError(t, err)
+			require.Equal(t, testCase.expected, out.String())
+		})
+	}
+}
+
+func TestPinoWriterFastPath(t *testing.T) {
+	logFormat := defaultFormat()
+	logFormat.messageKey = zerolog.MessageFieldName
+	logFormat.levelMarshaler = zerolog.LevelFieldMarshalFunc
+
+	event := []byte(`{"level":"info","message":"hello"}` + "\n")
+	prefixes := logFormat.levelPrefixes(nil, nil, zerolog.InfoLevel)
+	require.False(t, logFormat.rewrites(prefixes, event), "Events already matching the format are not rewritten")
+
+	logFormat.levelFormat = LevelFormatNumber
+	prefixes = logFormat.levelPrefixes(nil, nil, zerolog.InfoLevel)
+	require.True(t, logFormat.rewrites(prefixes, event))
+}
+
+func TestPinoWriterErrorStack(t *testing.T) {
+	logFormat := defaultFormat()
+	logFormat.errorStack = true
+
+	t.Run("Add the stack after the error field", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer := newPinoWriter(out, logFormat)
+
+		_, err := writer.WriteLevel(zerolog.ErrorLevel, []byte(`{"level":"error","error":"boom","pid":1}`+"\n"))
+		require.NoError(t, err)
+
+		result := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+		require.Equal(t, "boom", result["error"])
+		require.Contains(t, result["stack"], "TestPinoWriterErrorStack")
+		require.Equal(t, float64(1), result["pid"])
+	})
+
+	t.Run("Keep the stack already written", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer := newPinoWriter(out, logFormat)
+
+		_, err := writer.WriteLevel(zerolog.ErrorLevel, []byte(`{"level":"error","error":"boom","stack":"custom"}`+"\n"))
+		require.NoError(t, err)
+		require.Equal(t, `{"level":"50","error":"boom","stack":"custom"}`+"\n", out.String())
+	})
+
+	t.Run("Ignore nested error fields", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer := newPinoWriter(out, logFormat)
+
+		_, err := writer.WriteLevel(zerolog.ErrorLevel, []byte(`{"level":"error","request":{"error":"boom"}}`+"\n"))
+		require.NoError(t, err)
+		require.Equal(t, `{"level":"50","request":{"error":"boom"}}`+"\n", out.String())
+	})
+}
+
+func BenchmarkPinoWriter(b *testing.B) {
+	writer := newPinoWriter(&bytes.Buffer{}, defaultFormat())
+	event := []byte(`{"level":"info","pid":12739,"hostname":"bag-end","time":1618003000857,"msg":"there is no real going back","message":"there is no real going back"}` + "\n")
+
+	for i := 0; i < b.N; i++ {
+		_, _ = writer.WriteLevel(zerolog.InfoLevel, event)
+	}
+}
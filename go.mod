@@ -1,6 +1,6 @@
 module github.com/danibix95/zeropino
 
-go 1.20
+go 1.23
 
 require (
 	github.com/gofiber/fiber/v2 v2.47.0
@@ -17,7 +17,6 @@ require (
 	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/mattn/go-runewidth v0.0.14 // indirect
 	github.com/philhofer/fwd v1.1.2 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
 	github.com/savsgio/dictpool v0.0.0-20221023140959-7bf2e61cea94 // indirect
@@ -0,0 +1,181 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+// RegisterLevel registers a custom level with the given name and pino value, as pino
+// customLevels option does, and returns the zerolog level to log its events with,
+// e.g. logger.WithLevel(audit). Once registered, its name is accepted wherever a level
+// name is, such as InitOptions.Level, and its events are ordered by their pino value,
+// also by the loggers created before registering it.
+func RegisterLevel(name string, value int) (zerolog.Level, error) {
+	return pino.RegisterLevel(name, value)
+}
+
+// AtomicLevel is a logger level that can be changed while the program is running.
+// When provided in InitOptions, it controls the created logger and all the loggers
+// derived from it, such as the per-request loggers of zeropino middlewares.
+// It is also an http.Handler that allows to read and change the level.
+type AtomicLevel struct {
+	level atomic.Int32
+
+	mu       sync.Mutex
+	revert   *time.Timer
+	revertTo zerolog.Level
+}
+
+// NewAtomicLevel creates an AtomicLevel enabled at info level
+func NewAtomicLevel() *AtomicLevel {
+	l := &AtomicLevel{}
+	l.level.Store(int32(zerolog.InfoLevel))
+	return l
+}
+
+// Level returns the current level
+func (l *AtomicLevel) Level() zerolog.Level {
+	return zerolog.Level(l.level.Load())
+}
+
+// SetLevel changes the current level, cancelling any pending revert
+func (l *AtomicLevel) SetLevel(level zerolog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stopRevert()
+	l.level.Store(int32(level))
+}
+
+// SetLevelFor changes the current level and restores the previous one once
+// the duration has elapsed. When another revert is already pending, the level
+// restored is the one that was set before the pending revert was scheduled.
+func (l *AtomicLevel) SetLevelFor(level zerolog.Level, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	revertTo := l.Level()
+	if l.stopRevert() {
+		revertTo = l.revertTo
+	}
+
+	l.level.Store(int32(level))
+	l.revertTo = revertTo
+
+	var timer *time.Timer
+	timer = time.AfterFunc(duration, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		// a newer change has already replaced this revert
+		if l.revert != timer {
+			return
+		}
+		l.revert = nil
+		l.level.Store(int32(revertTo))
+	})
+	l.revert = timer
+}
+
+// stopRevert cancels the pending revert, reporting whether there was one
+func (l *AtomicLevel) stopRevert() bool {
+	if l.revert == nil {
+		return false
+	}
+
+	l.revert.Stop()
+	l.revert = nil
+	return true
+}
+
+// Enabled reports whether events of the given level should be logged
+func (l *AtomicLevel) Enabled(level zerolog.Level) bool {
+	return pino.Enabled(level, l.Level())
+}
+
+// maxLevelBodySize limits the size of the body read by AtomicLevel handler
+const maxLevelBodySize = 4096
+
+type levelPayload struct {
+	Level       string `json:"level"`
+	RevertAfter string `json:"revertAfter,omitempty"`
+}
+
+type levelError struct {
+	Error string `json:"error"`
+}
+
+// ServeHTTP implements http.Handler interface. A GET request returns the current
+// level, while a PUT request changes it, reading a JSON body such as
+// {"level": "debug", "revertAfter": "5m"}, where revertAfter is optional and
+// accepts any value supported by time.ParseDuration
+func (l *AtomicLevel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var payload levelPayload
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLevelBodySize)).Decode(&payload); err != nil {
+			writeLevelResponse(w, http.StatusBadRequest, levelError{Error: fmt.Sprintf("invalid request body: %s", err)})
+			return
+		}
+
+		if payload.Level == "" {
+			writeLevelResponse(w, http.StatusBadRequest, levelError{Error: "level is required"})
+			return
+		}
+
+		level, err := pino.ParseLevel(payload.Level)
+		if err != nil {
+			writeLevelResponse(w, http.StatusBadRequest, levelError{Error: err.Error()})
+			return
+		}
+
+		if payload.RevertAfter == "" {
+			l.SetLevel(level)
+			break
+		}
+
+		duration, err := time.ParseDuration(payload.RevertAfter)
+		if err != nil || duration <= 0 {
+			writeLevelResponse(w, http.StatusBadRequest, levelError{Error: fmt.Sprintf("revertAfter %s is not a valid duration", payload.RevertAfter)})
+			return
+		}
+		l.SetLevelFor(level, duration)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+		writeLevelResponse(w, http.StatusMethodNotAllowed, levelError{Error: fmt.Sprintf("method %s is not allowed", r.Method)})
+		return
+	}
+
+	writeLevelResponse(w, http.StatusOK, levelPayload{Level: pino.LevelName(l.Level())})
+}
+
+func writeLevelResponse(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
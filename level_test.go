@@ -0,0 +1,191 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+const levelPath = "/-/log-level"
+
+func TestAtomicLevel(t *testing.T) {
+	t.Run("Initialize a Logger with an atomic level", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		level := NewAtomicLevel()
+		logger, err := Init(InitOptions{Level: "warn", Writer: out, AtomicLevel: level})
+		require.Nil(t, err)
+		require.Equal(t, zerolog.WarnLevel, level.Level(), "Atomic level is initialized with Level option")
+
+		logger.Info().Msg(message)
+		require.Equal(t, 0, out.Len(), "No output should be produced due to msg logged using lower level than enabled one")
+
+		level.SetLevel(zerolog.DebugLevel)
+
+		derived := logger.With().Str("reqId", "my-id").Logger()
+		derived.Debug().Msg(message)
+		require.Contains(t, out.String(), `"reqId":"my-id"`, "Derived loggers follow the level changes")
+	})
+
+	t.Run("Keep the atomic level when sampling is replaced or disabled", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		level := NewAtomicLevel()
+		logger, err := Init(InitOptions{Level: "error", Writer: out, AtomicLevel: level})
+		require.NoError(t, err)
+		require.Equal(t, zerolog.TraceLevel, logger.GetLevel(), "Zerolog level does not filter any event")
+
+		sampled := logger.Sample(&zerolog.BasicSampler{N: 1})
+		sampled.Info().Msg(message)
+		require.Equal(t, 0, out.Len(), "Replacing the sampler keeps the atomic level")
+
+		zerolog.DisableSampling(true)
+		defer zerolog.DisableSampling(false)
+		logger.Info().Msg(message)
+		require.Equal(t, 0, out.Len(), "Disabling sampling keeps the atomic level")
+
+		level.SetLevel(zerolog.InfoLevel)
+		sampled.Info().Msg(message)
+		require.Contains(t, out.String(), message, "Derived loggers follow the level changes")
+	})
+
+	t.Run("Revert level after the given duration", func(t *testing.T) {
+		level := NewAtomicLevel()
+
+		level.SetLevelFor(zerolog.DebugLevel, time.Hour)
+		level.SetLevelFor(zerolog.TraceLevel, 10*time.Millisecond)
+		require.Equal(t, zerolog.TraceLevel, level.Level())
+
+		require.Eventually(t, func() bool {
+			return level.Level() == zerolog.InfoLevel
+		}, time.Second, 5*time.Millisecond, "Level set before the pending revert is restored")
+	})
+
+	t.Run("Setting a level cancels pending reverts", func(t *testing.T) {
+		level := NewAtomicLevel()
+
+		level.SetLevelFor(zerolog.DebugLevel, 10*time.Millisecond)
+		level.SetLevel(zerolog.ErrorLevel)
+
+		time.Sleep(30 * time.Millisecond)
+		require.Equal(t, zerolog.ErrorLevel, level.Level())
+	})
+}
+
+func TestStepLevel(t *testing.T) {
+	require.Equal(t, zerolog.TraceLevel, pino.StepLevel(zerolog.TraceLevel, -1), "Trace is the lowest level")
+	require.Equal(t, zerolog.PanicLevel, pino.StepLevel(zerolog.PanicLevel, 1), "Panic is the highest level")
+	require.Equal(t, zerolog.PanicLevel, pino.StepLevel(zerolog.Disabled, -1), "Disabled level is lowered to panic")
+	require.Equal(t, zerolog.Disabled, pino.StepLevel(zerolog.Disabled, 1))
+}
+
+func TestAtomicLevelHandler(t *testing.T) {
+	testCases := []struct {
+		name          string
+		method        string
+		body          string
+		statusCode    int
+		response      string
+		expectedLevel zerolog.Level
+	}{
+		{
+			name:          "read current level",
+			method:        http.MethodGet,
+			statusCode:    http.StatusOK,
+			response:      `{"level":"info"}`,
+			expectedLevel: zerolog.InfoLevel,
+		},
+		{
+			name:          "change current level",
+			method:        http.MethodPut,
+			body:          `{"level":"DEBUG"}`,
+			statusCode:    http.StatusOK,
+			response:      `{"level":"debug"}`,
+			expectedLevel: zerolog.DebugLevel,
+		},
+		{
+			name:          "disable logs",
+			method:        http.MethodPut,
+			body:          `{"level":"silent","revertAfter":"1m"}`,
+			statusCode:    http.StatusOK,
+			response:      `{"level":"silent"}`,
+			expectedLevel: zerolog.Disabled,
+		},
+		{
+			name:          "unknown level",
+			method:        http.MethodPut,
+			body:          `{"level":"verbose"}`,
+			statusCode:    http.StatusBadRequest,
+			response:      `{"error":"level verbose is not recognized"}`,
+			expectedLevel: zerolog.InfoLevel,
+		},
+		{
+			name:          "missing level",
+			method:        http.MethodPut,
+			body:          `{}`,
+			statusCode:    http.StatusBadRequest,
+			response:      `{"error":"level is required"}`,
+			expectedLevel: zerolog.InfoLevel,
+		},
+		{
+			name:          "invalid revert duration",
+			method:        http.MethodPut,
+			body:          `{"level":"debug","revertAfter":"soon"}`,
+			statusCode:    http.StatusBadRequest,
+			response:      `{"error":"revertAfter soon is not a valid duration"}`,
+			expectedLevel: zerolog.InfoLevel,
+		},
+		{
+			name:          "oversized body",
+			method:        http.MethodPut,
+			body:          `{"level":"debug","padding":"` + strings.Repeat("x", maxLevelBodySize) + `"}`,
+			statusCode:    http.StatusBadRequest,
+			response:      `{"error":"invalid request body: http: request body too large"}`,
+			expectedLevel: zerolog.InfoLevel,
+		},
+		{
+			name:          "method not allowed",
+			method:        http.MethodPost,
+			body:          `{"level":"debug"}`,
+			statusCode:    http.StatusMethodNotAllowed,
+			response:      `{"error":"method POST is not allowed"}`,
+			expectedLevel: zerolog.InfoLevel,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			level := NewAtomicLevel()
+
+			request := httptest.NewRequest(testCase.method, levelPath, strings.NewReader(testCase.body))
+			recorder := httptest.NewRecorder()
+			level.ServeHTTP(recorder, request)
+
+			require.Equal(t, testCase.statusCode, recorder.Code)
+			require.JSONEq(t, testCase.response, recorder.Body.String())
+			require.Equal(t, testCase.expectedLevel, level.Level())
+		})
+	}
+}
@@ -21,59 +21,230 @@ import (
 	"os"
 
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/pkgerrors"
 
-	pino "github.com/danibix95/zeropino/internal/model"
+	"github.com/danibix95/zeropino/pino"
 )
 
 // InitOptions are the possible options that can be used to initialize the logger
 type InitOptions struct {
-	Level         string
+	Level string
+	// LevelFormat selects how the level is written, LevelFormatString by default
+	LevelFormat LevelFormat
+	// LabelKey is the key of the level name written by LevelFormatNumberAndLabel, "label" by default
+	LabelKey string
+	// TimeFormat selects how the time of each log is written, TimeFormatEpochMs by default
+	TimeFormat TimeFormat
+	// TimeKey is the key of the time field, "time" by default
+	TimeKey string
+	// Deprecated: DisableTimeMs is equivalent to TimeFormat set to TimeFormatEpochSeconds
+	// and it is ignored when TimeFormat is set.
 	DisableTimeMs bool
-	Writer        io.Writer
+	// Writer is where logs are written, os.Stdout by default. It is equivalent
+	// to a destination without minimum level and it is kept for backward compatibility.
+	Writer io.Writer
+	// Destinations are the writers logs are written to, each one with its own minimum level.
+	// When both Writer and Destinations are set, Writer is added as the first destination.
+	Destinations []Destination
+	// AtomicLevel, when set, allows to change the level of the logger and of
+	// all the loggers derived from it while the program is running.
+	// It is initialized with the value of Level option.
+	AtomicLevel *AtomicLevel
+	// LevelSignals, when enabled, lowers the logger level by one step at each SIGUSR1
+	// and raises it at each SIGUSR2, logging every transition. It is available on Unix only.
+	// Once the function returned by InitWithStop is called, these signals are handled again
+	// as before, which terminates the process unless the program handles them elsewhere.
+	LevelSignals bool
+	// Async, when set, writes the logs from a background goroutine through a bounded buffer,
+	// so that loggers are not blocked by a slow Writer. Buffered logs are written when
+	// the function returned by InitWithStop is called.
+	Async *AsyncOptions
+	// Sampling, when set, keeps only a part of the events of the configured levels
+	Sampling *SamplingOptions
+	// Redact, when set, censors the fields matching the given paths in every log
+	Redact *RedactOptions
+	// Base are static fields added to every log, such as service name, version or environment
+	Base map[string]interface{}
+	// PidKey is the key of the process id field, "pid" by default
+	PidKey string
+	// OmitPid removes the process id field from every log
+	OmitPid bool
+	// HostnameKey is the key of the hostname field, "hostname" by default
+	HostnameKey string
+	// OmitHostname removes the hostname field from every log
+	OmitHostname bool
+	// ErrorStack adds the stack of the code logging an error under the stack key
+	// to the events carrying a top level error field, e.g. the ones built with Err method,
+	// including the ones whose error field is written by other methods, such as Str.
+	// It is not the stack carried by the error, which is written by ErrorObject.
+	ErrorStack bool
 }
 
-// Init Creates a zerolog logger with custom default properties and custom style
+// Init Creates a zerolog logger with custom default properties and custom style.
+// Resources installed by the options, such as signal handlers, are kept until
+// the program ends: use InitWithStop to release them earlier.
 func Init(options InitOptions) (*zerolog.Logger, error) {
-	var logWriter io.Writer = os.Stdout
-	if options.Writer != nil {
-		logWriter = options.Writer
-	}
+	logger, _, err := InitWithStop(options)
+	return logger, err
+}
 
+// InitWithStop Creates a zerolog logger as Init, returning also a function
+// that releases the resources installed by the options, such as signal handlers.
+// After stopping, the signals watched by LevelSignals get back their default behavior,
+// so receiving SIGUSR1 or SIGUSR2 terminates the process.
+func InitWithStop(options InitOptions) (*zerolog.Logger, func(), error) {
 	logLevel, err := pino.ParseLevel(options.Level)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	logWriter, err := optionsWriter(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logFormat := defaultFormat()
+	if logFormat.timeFormat, err = parseTimeFormat(string(options.TimeFormat)); err != nil {
+		return nil, nil, err
+	}
+	if options.TimeFormat == "" && options.DisableTimeMs {
+		logFormat.timeFormat = TimeFormatEpochSeconds
+	}
+	if options.TimeKey != "" {
+		logFormat.timeKey = options.TimeKey
+	}
+	logFormat.levelFormat = options.LevelFormat
+	if options.LabelKey != "" {
+		logFormat.labelKey = options.LabelKey
+	}
+	if options.PidKey != "" {
+		logFormat.pidKey = options.PidKey
+	}
+	if options.OmitPid {
+		logFormat.pidKey = ""
+	}
+	if options.HostnameKey != "" {
+		logFormat.hostnameKey = options.HostnameKey
+	}
+	if options.OmitHostname {
+		logFormat.hostnameKey = ""
+	}
+	logFormat.base = options.Base
+	logFormat.errorStack = options.ErrorStack
+	if options.Redact != nil {
+		if logFormat.redactor, err = newRedactor(*options.Redact); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	atomicLevel := options.AtomicLevel
+	if atomicLevel == nil && options.LevelSignals {
+		atomicLevel = NewAtomicLevel()
+	}
+
+	// the level filter checks the events of custom levels, which zerolog cannot order,
+	// at each event, so that they are filtered whenever they have been registered
+	filter := levelFilter{level: atomicLevel, minimum: logLevel}
+	logSampler, err := newSampler(filter, options.Sampling)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return createLogger(logWriter, logLevel, options.DisableTimeMs), nil
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if options.Async != nil {
+		asyncWriter, err := newAsyncWriter(logWriter, *options.Async, logFormat)
+		if err != nil {
+			return nil, nil, err
+		}
+		stops = append(stops, func() { _ = asyncWriter.Close() })
+		logWriter = asyncWriter
+	}
+
+	logger := createLogger(logWriter, logLevel, logFormat)
+	if atomicLevel != nil {
+		atomicLevel.SetLevel(logLevel)
+
+		// the fixed level must not filter any event, since AtomicLevel takes care of it
+		*logger = logger.Level(zerolog.TraceLevel)
+	} else if pino.IsCustomLevel(logLevel) {
+		// zerolog cannot order custom levels, so the level filter takes its place
+		*logger = logger.Level(zerolog.TraceLevel)
+	}
+
+	if options.LevelSignals {
+		// level transitions are always notified, whatever the current level is
+		stopSignals, err := watchLevelSignals(atomicLevel, *logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		stops = append(stops, stopSignals)
+	}
+
+	sampledLogger := logger.Hook(levelHook{filter: filter}).Sample(logSampler)
+	return &sampledLogger, stop, nil
+}
+
+// optionsWriter returns the writer of all the destinations set in the options
+func optionsWriter(options InitOptions) (io.Writer, error) {
+	if len(options.Destinations) == 0 {
+		if options.Writer != nil {
+			return options.Writer, nil
+		}
+		return os.Stdout, nil
+	}
+
+	destinations := options.Destinations
+	if options.Writer != nil {
+		destinations = append([]Destination{{Writer: options.Writer}}, destinations...)
+	}
+	return newMultiLevelWriter(destinations)
 }
 
 // InitDefault Creates a zerolog logger with custom default properties
 // and custom style using predefined writer and log level
 func InitDefault() *zerolog.Logger {
-	return createLogger(os.Stdout, zerolog.InfoLevel, false)
-}
+	logger := createLogger(os.Stdout, zerolog.InfoLevel, defaultFormat())
 
-func createLogger(writer io.Writer, level zerolog.Level, disableTimeMs bool) *zerolog.Logger {
-	// global default configuration
-	zerolog.MessageFieldName = "msg"
-	zerolog.LevelFieldMarshalFunc = pino.ConvertLevel
-	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
+	// without sampling options no error is returned
+	filter := levelFilter{minimum: zerolog.InfoLevel}
+	logSampler, _ := newSampler(filter, nil)
+	sampledLogger := logger.Hook(levelHook{filter: filter}).Sample(logSampler)
+	return &sampledLogger
+}
 
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMs
-	if disableTimeMs {
-		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+// createLogger builds a logger that applies its own format to the events,
+// so that zerolog package globals, shared with any other zerolog logger
+// in the process, are never modified
+func createLogger(writer io.Writer, level zerolog.Level, logFormat format) *zerolog.Logger {
+	log := zerolog.New(newPinoWriter(writer, logFormat))
+	if logFormat.timeFormat != TimeFormatDisabled {
+		log = log.Hook(timestampHook{key: logFormat.timeKey, format: logFormat.timeFormat})
+	}
+	// the message is written last, right before the one written by zerolog,
+	// so that the writer finds the duplicate without scanning the event
+	if zerolog.MessageFieldName != logFormat.messageKey {
+		log = log.Hook(messageHook{key: logFormat.messageKey})
 	}
 
-	// ignore hostname in case of error
-	hostname, _ := os.Hostname()
+	context := log.With()
 
-	log := zerolog.New(writer).With().
-		Timestamp().
-		Int("pid", os.Getpid()).
-		Str("hostname", hostname).
-		Logger().
-		Level(level)
+	if logFormat.pidKey != "" {
+		context = context.Int(logFormat.pidKey, os.Getpid())
+	}
+	if logFormat.hostnameKey != "" {
+		// ignore hostname in case of error
+		hostname, _ := os.Hostname()
+		context = context.Str(logFormat.hostnameKey, hostname)
+	}
+	if len(logFormat.base) > 0 {
+		context = context.Fields(logFormat.base)
+	}
 
+	log = context.Logger().Level(level)
 	return &log
 }
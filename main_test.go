@@ -19,14 +19,20 @@ package zeropino
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
-	pino "github.com/danibix95/zeropino/internal/model"
+	"github.com/danibix95/zeropino/pino"
 )
 
 type miaLog struct {
@@ -39,6 +45,7 @@ type miaLog struct {
 }
 
 const message = "Follow the spiders!"
+
 const unixTimestampLen = 10
 const unixTimestampMsLen = 13
 
@@ -113,6 +120,191 @@ func TestInit(t *testing.T) {
 
 		verifyLog(t, &result, message, string(pino.Warn), unixTimestampLen)
 	})
+
+	t.Run("Initialize Loggers with different options in the same process", func(t *testing.T) {
+		outMs := &bytes.Buffer{}
+		loggerMs, err := Init(InitOptions{Writer: outMs})
+		verifyInit(t, loggerMs, err, zerolog.InfoLevel)
+
+		outSeconds := &bytes.Buffer{}
+		loggerSeconds, err := Init(InitOptions{Writer: outSeconds, DisableTimeMs: true})
+		verifyInit(t, loggerSeconds, err, zerolog.InfoLevel)
+
+		loggerMs.Info().Msg(message)
+		loggerSeconds.Info().Msg(message)
+
+		resultMs := miaLog{}
+		require.Nil(t, json.Unmarshal(outMs.Bytes(), &resultMs), "No error raised")
+		verifyLog(t, &resultMs, message, string(pino.Info), unixTimestampMsLen)
+
+		resultSeconds := miaLog{}
+		require.Nil(t, json.Unmarshal(outSeconds.Bytes(), &resultSeconds), "No error raised")
+		verifyLog(t, &resultSeconds, message, string(pino.Info), unixTimestampLen)
+	})
+
+	t.Run("Initialize a Logger with the selected time format and key", func(t *testing.T) {
+		testCases := []struct {
+			format TimeFormat
+			verify func(t *testing.T, value interface{})
+		}{
+			{TimeFormatEpochNs, func(t *testing.T, value interface{}) {
+				require.IsType(t, json.Number(""), value)
+				require.Len(t, value.(json.Number).String(), 19)
+			}},
+			{TimeFormatRFC3339, func(t *testing.T, value interface{}) {
+				_, err := time.Parse(time.RFC3339, value.(string))
+				require.NoError(t, err)
+			}},
+			{TimeFormatRFC3339Nano, func(t *testing.T, value interface{}) {
+				_, err := time.Parse(time.RFC3339Nano, value.(string))
+				require.NoError(t, err)
+			}},
+		}
+
+		for _, testCase := range testCases {
+			out := &bytes.Buffer{}
+			logger, err := Init(InitOptions{Writer: out, TimeFormat: testCase.format, TimeKey: "timestamp"})
+			verifyInit(t, logger, err, zerolog.InfoLevel)
+			logger.Info().Msg(message)
+
+			result := map[string]interface{}{}
+			decoder := json.NewDecoder(out)
+			decoder.UseNumber()
+			require.Nil(t, decoder.Decode(&result), "No error raised")
+			require.NotContains(t, result, "time")
+			require.Contains(t, result, "timestamp")
+			testCase.verify(t, result["timestamp"])
+		}
+	})
+
+	t.Run("Initialize a Logger with time format taking precedence over DisableTimeMs", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, TimeFormat: TimeFormatEpochMs, DisableTimeMs: true})
+		verifyInit(t, logger, err, zerolog.InfoLevel)
+		logger.Info().Msg(message)
+
+		result := miaLog{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		verifyLog(t, &result, message, string(pino.Info), unixTimestampMsLen)
+	})
+
+	t.Run("Initialize a Logger without time", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, TimeFormat: TimeFormatDisabled})
+		verifyInit(t, logger, err, zerolog.InfoLevel)
+		logger.Info().Msg(message)
+
+		result := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		require.NotContains(t, result, "time")
+	})
+
+	t.Run("Initialize a Logger with an unknown time format", func(t *testing.T) {
+		logger, err := Init(InitOptions{TimeFormat: "iso"})
+
+		var emptyPointer *zerolog.Logger
+		require.EqualError(t, err, "time format iso is not recognized")
+		require.Equal(t, emptyPointer, logger)
+	})
+
+	t.Run("Initialize a Logger with numeric level and label", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, LevelFormat: LevelFormatNumberAndLabel, LabelKey: "severity"})
+		verifyInit(t, logger, err, zerolog.InfoLevel)
+		logger.Info().Msg(message)
+
+		result := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		require.Equal(t, float64(30), result["level"])
+		require.Equal(t, "info", result["severity"])
+		require.Equal(t, message, result["msg"])
+	})
+
+	t.Run("Initialize a Logger with base fields", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{
+			Writer:      out,
+			Base:        map[string]interface{}{"service": "shire", "version": "1.2.3"},
+			HostnameKey: "pod",
+		})
+		verifyInit(t, logger, err, zerolog.InfoLevel)
+		logger.Info().Msg(message)
+
+		hostname, _ := os.Hostname()
+		result := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		require.Equal(t, "shire", result["service"])
+		require.Equal(t, "1.2.3", result["version"])
+		require.Equal(t, hostname, result["pod"], "Hostname is renamed")
+		require.NotContains(t, result, "hostname")
+		require.Equal(t, float64(os.Getpid()), result["pid"])
+	})
+
+	t.Run("Initialize a Logger without pid and hostname", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, OmitPid: true, OmitHostname: true})
+		verifyInit(t, logger, err, zerolog.InfoLevel)
+		logger.Info().Msg(message)
+
+		result := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		require.NotContains(t, result, "pid")
+		require.NotContains(t, result, "hostname")
+		require.Len(t, result, 3, "Only level, time and msg are logged")
+	})
+
+	t.Run("Initialize a Logger keeps the fields named as zerolog message", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, OmitPid: true, OmitHostname: true, TimeFormat: TimeFormatDisabled})
+		require.NoError(t, err)
+
+		logger.Info().Str(zerolog.MessageFieldName, "from the user").Send()
+		logger.Info().Str(zerolog.MessageFieldName, "from the user").Msg(message)
+
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		require.Len(t, lines, 2)
+		require.JSONEq(t, `{"level":"30","message":"from the user"}`, string(lines[0]))
+		require.JSONEq(t, `{"level":"30","message":"from the user","msg":"Follow the spiders!"}`, string(lines[1]))
+	})
+
+	t.Run("Initialize a Logger with error stack", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, ErrorStack: true})
+		require.NoError(t, err)
+
+		logger.Error().Err(errors.New("the ring is lost")).Msg(message)
+
+		result := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+		require.Equal(t, "the ring is lost", result[zerolog.ErrorFieldName])
+		stack, _ := result[zerolog.ErrorStackFieldName].(string)
+		require.True(t, strings.HasPrefix(stack, "github.com/danibix95/zeropino.TestInit."), "Stack starts at the logging code")
+	})
+
+	t.Run("Initialize a Logger does not change zerolog global settings", func(t *testing.T) {
+		messageFieldName := zerolog.MessageFieldName
+		timeFieldFormat := zerolog.TimeFieldFormat
+
+		_, err := Init(InitOptions{DisableTimeMs: true})
+		require.Nil(t, err)
+		InitDefault()
+
+		require.Equal(t, messageFieldName, zerolog.MessageFieldName)
+		require.Equal(t, timeFieldFormat, zerolog.TimeFieldFormat)
+
+		out := &bytes.Buffer{}
+		plain := zerolog.New(out)
+		plain.Info().Msg(message)
+		require.JSONEq(t, `{"level":"info","message":"Follow the spiders!"}`, out.String())
+	})
+}
+
+func TestEpochTime(t *testing.T) {
+	written := time.Unix(1629275400, 123456789)
+
+	require.True(t, EpochTime(written.Unix()).Equal(written.Truncate(time.Second)))
+	require.True(t, EpochTime(written.UnixMilli()).Equal(written.Truncate(time.Millisecond)))
+	require.True(t, EpochTime(written.UnixNano()).Equal(written))
 }
 
 func BenchmarkZeropino(b *testing.B) {
@@ -123,6 +315,15 @@ func BenchmarkZeropino(b *testing.B) {
 	}
 }
 
+func BenchmarkZeropinoFields(b *testing.B) {
+	logger, _ := Init(InitOptions{Level: "trace", Writer: io.Discard})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		logger.Warn().Str("reqId", "16c9c1f2-c001-40d3-bbfe-48857367e7b5").Int("statusCode", 200).Msg(message)
+	}
+}
+
 func verifyLog(t testing.TB, log *miaLog, msg, level string, timeLen int) {
 	t.Helper()
 
@@ -137,3 +338,20 @@ func verifyInit(t testing.TB, logger *zerolog.Logger, err error, expected zerolo
 	require.Nil(t, err, "No init error should be encountered")
 	require.Equal(t, expected, logger.GetLevel(), "Level value")
 }
+
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
@@ -0,0 +1,132 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"mime"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the number of bytes logged of each captured body when no limit is configured
+const DefaultMaxBodyBytes = 4096
+
+// defaultBodyContentTypes are the JSON, form and text media types, whose bodies are readable once logged
+var defaultBodyContentTypes = []string{
+	"application/json",
+	"application/*+json",
+	"application/x-www-form-urlencoded",
+	"text/*",
+}
+
+// BodyCapture configures the logging of request and response bodies
+type BodyCapture struct {
+	// Enabled logs the bodies under http.request.body.content and http.response.body.content
+	// fields of the request completed log
+	Enabled bool
+	// MaxBytes is the number of bytes logged of each body, DefaultMaxBodyBytes by default
+	MaxBytes int
+	// ContentTypes are the media types of the bodies to log, where * matches any type or
+	// subtype, e.g. text/* or application/*+json. JSON, form and text bodies are logged by default.
+	ContentTypes []string
+	// OnlyErrors logs the bodies only of the requests completed with a 4xx or 5xx status code
+	OnlyErrors bool
+}
+
+// Limit returns the number of bytes logged of each body
+func (b BodyCapture) Limit() int {
+	if b.MaxBytes <= 0 {
+		return DefaultMaxBodyBytes
+	}
+	return b.MaxBytes
+}
+
+// CapturesStatus reports whether the bodies of a request completed with the status code are logged
+func (b BodyCapture) CapturesStatus(statusCode int) bool {
+	return b.Enabled && (!b.OnlyErrors || statusCode >= 400)
+}
+
+// CapturesContent reports whether a body of the given Content-Type and Content-Encoding headers
+// is logged. Compressed bodies are never logged, since they would not be readable.
+func (b BodyCapture) CapturesContent(contentType, contentEncoding string) bool {
+	if !b.Enabled || (contentEncoding != "" && !strings.EqualFold(contentEncoding, "identity")) {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	allowed := b.ContentTypes
+	if len(allowed) == 0 {
+		allowed = defaultBodyContentTypes
+	}
+	for _, pattern := range allowed {
+		if matchMediaType(strings.ToLower(pattern), mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMediaType reports whether the media type matches the pattern,
+// whose type and subtype can contain a * wildcard
+func matchMediaType(pattern, mediaType string) bool {
+	patternType, patternSubtype, ok := strings.Cut(pattern, "/")
+	if !ok {
+		return false
+	}
+	mainType, subtype, _ := strings.Cut(mediaType, "/")
+
+	return matchWildcard(patternType, mainType) && matchWildcard(patternSubtype, subtype)
+}
+
+func matchWildcard(pattern, value string) bool {
+	prefix, suffix, ok := strings.Cut(pattern, "*")
+	if !ok {
+		return pattern == value
+	}
+	return len(value) >= len(prefix)+len(suffix) && strings.HasPrefix(value, prefix) && strings.HasSuffix(value, suffix)
+}
+
+// BodyBuffer keeps the first bytes written to it, up to its limit, discarding the others
+type BodyBuffer struct {
+	limit int
+	data  []byte
+}
+
+// NewBodyBuffer creates a BodyBuffer keeping up to limit bytes
+func NewBodyBuffer(limit int) *BodyBuffer {
+	return &BodyBuffer{limit: limit}
+}
+
+// Write implements io.Writer interface, never failing
+func (b *BodyBuffer) Write(p []byte) (int, error) {
+	if available := b.limit - len(b.data); available > 0 {
+		if len(p) > available {
+			b.data = append(b.data, p[:available]...)
+		} else {
+			b.data = append(b.data, p...)
+		}
+	}
+	return len(p), nil
+}
+
+// String returns the bytes kept by the buffer
+func (b *BodyBuffer) String() string {
+	return string(b.data)
+}
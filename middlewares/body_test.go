@@ -0,0 +1,68 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyCaptureContent(t *testing.T) {
+	capture := BodyCapture{Enabled: true}
+
+	for _, contentType := range []string{"application/json", "application/problem+json", "application/x-www-form-urlencoded", "text/plain; charset=utf-8", "TEXT/HTML"} {
+		require.True(t, capture.CapturesContent(contentType, ""), contentType)
+	}
+	for _, contentType := range []string{"", "application/octet-stream", "image/png", "multipart/form-data; boundary=x", "invalid/"} {
+		require.False(t, capture.CapturesContent(contentType, ""), contentType)
+	}
+
+	require.True(t, capture.CapturesContent("application/json", "identity"))
+	require.False(t, capture.CapturesContent("application/json", "gzip"), "Compressed bodies are not captured")
+
+	capture.ContentTypes = []string{"application/xml"}
+	require.True(t, capture.CapturesContent("application/xml", ""))
+	require.False(t, capture.CapturesContent("application/json", ""))
+
+	require.False(t, BodyCapture{}.CapturesContent("application/json", ""), "Disabled capture never captures")
+}
+
+func TestBodyCaptureStatus(t *testing.T) {
+	require.False(t, BodyCapture{}.CapturesStatus(500))
+	require.True(t, BodyCapture{Enabled: true}.CapturesStatus(200))
+	require.False(t, BodyCapture{Enabled: true, OnlyErrors: true}.CapturesStatus(302))
+	require.True(t, BodyCapture{Enabled: true, OnlyErrors: true}.CapturesStatus(404))
+
+	require.Equal(t, DefaultMaxBodyBytes, BodyCapture{}.Limit())
+	require.Equal(t, 10, BodyCapture{MaxBytes: 10}.Limit())
+}
+
+func TestBodyBuffer(t *testing.T) {
+	buffer := NewBodyBuffer(8)
+
+	n, err := buffer.Write([]byte("hello "))
+	require.NoError(t, err)
+	require.Equal(t, 6, n)
+
+	n, err = buffer.Write([]byte("world"))
+	require.NoError(t, err)
+	require.Equal(t, 5, n, "Discarded bytes are reported as written")
+
+	_, _ = buffer.Write([]byte("!"))
+	require.Equal(t, "hello wo", buffer.String())
+}
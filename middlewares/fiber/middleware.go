@@ -17,49 +17,87 @@
 package fiber
 
 import (
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+
+	zp "github.com/danibix95/zeropino"
+	zpm "github.com/danibix95/zeropino/middlewares"
 )
 
 const million float64 = 1000000
 const (
-	contentLengthHeaderKey = "Content-Length"
-	userAgentHeaderKey     = "User-Agent"
-	requestIDHeaderKey     = "X-Request-ID"
-	forwardedHostHeaderKey = "X-Forwarded-Host"
-	forwardedForHeaderKey  = "X-Forwarded-For"
+	contentLengthHeaderKey   = "Content-Length"
+	contentTypeHeaderKey     = "Content-Type"
+	contentEncodingHeaderKey = "Content-Encoding"
+	userAgentHeaderKey       = "User-Agent"
+	forwardedHostHeaderKey   = "X-Forwarded-Host"
+	forwardedForHeaderKey    = "X-Forwarded-For"
 )
 
 // RequestLogger is a fiber middleware to log all requests with a custom zerolog Logger
 // It logs both when requests arrive and when they are completed, adding request latency
 func RequestLogger(l *zerolog.Logger) func(*fiber.Ctx) error {
+	return RequestLoggerWithOptions(l, zpm.Options{})
+}
+
+// RequestLoggerWithOptions is the RequestLogger middleware configured by the provided options.
+// The request ID is read from the configured headers, generated when none is valid, and
+// set on the response. Unless disabled, the W3C trace context of each request is added to
+// its logger and stored in its user context, generating a new one when traceparent
+// header is missing or invalid. When body capture is enabled, the beginning of the request
+// and response bodies are logged once the request is completed.
+// The completed request log contains also the path of the route matched by the request,
+// or the one recorded through middlewares.SetRoute on the user context.
+func RequestLoggerWithOptions(l *zerolog.Logger, options zpm.Options) func(*fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		start := time.Now()
 
-		sub := l.With().Str("reqId", extractRequestID(l, c)).Logger()
+		var traceContext zpm.TraceContext
+		if !options.DisableTraceContext {
+			// the request headers are left as sent by the client
+			traceContext, _ = zpm.RequestTraceContext(func(key string) string { return c.Get(key) })
+			c.SetUserContext(zpm.WithTraceContext(c.UserContext(), traceContext))
+		}
+
+		requestID := extractRequestID(l, c, options, traceContext.TraceID)
+		if header := options.ResponseRequestIDHeader(); header != "" && requestID != "" {
+			c.Set(header, requestID)
+		}
+
+		loggerContext := l.With().Str("reqId", requestID)
+		if !options.DisableTraceContext {
+			loggerContext = loggerContext.
+				Str("traceId", traceContext.TraceID).
+				Str("spanId", traceContext.SpanID).
+				Bool("traceSampled", traceContext.Sampled)
+		}
+		sub := loggerContext.Logger()
 		WithLogger(c, &sub)
 
-		logIncoming(c)
+		for _, prefix := range options.ExcludedPrefixes {
+			if strings.HasPrefix(string(c.Request().URI().RequestURI()), prefix) {
+				return c.Next()
+			}
+		}
+
+		logIncoming(c, options.Headers)
+		middlewareRoute := c.Route()
+		c.SetUserContext(zpm.WithRouteCapture(c.UserContext()))
 		err := c.Next()
-		logCompleted(c, start)
+		logCompleted(c, start, options, getRoute(c, middlewareRoute))
 
 		return err
 	}
 }
 
-func logIncoming(c *fiber.Ctx) {
+func logIncoming(c *fiber.Ctx, headers zpm.HeaderLogging) {
 	ReqLogger(c).Trace().
 		Dict("http", zerolog.Dict().
-			Dict("request", zerolog.Dict().
-				Str("method", c.Method()).
-				Dict("userAgent", zerolog.Dict().
-					Str("original", c.Get(userAgentHeaderKey)),
-				),
-			),
+			Dict("request", requestDict(c, headers)),
 		).
 		Dict("url", zerolog.Dict().
 			Str("path", string(c.Request().URI().RequestURI())),
@@ -72,25 +110,47 @@ func logIncoming(c *fiber.Ctx) {
 		Msg("incoming request")
 }
 
-func logCompleted(c *fiber.Ctx, start time.Time) {
-	ReqLogger(c).Info().
+func logCompleted(c *fiber.Ctx, start time.Time, options zpm.Options, route string) {
+	capture := options.BodyCapture
+	request := requestDict(c, options.Headers)
+	responseBodyDict := zerolog.Dict().
+		Int("bytes", c.Response().Header.ContentLength())
+
+	// streamed bodies are never captured, since reading them would consume the whole stream,
+	// which may never end, e.g. for text/event-stream responses
+	if capture.CapturesStatus(c.Response().StatusCode()) {
+		if !c.Request().IsBodyStream() && capture.CapturesContent(c.Get(contentTypeHeaderKey), c.Get(contentEncodingHeaderKey)) && len(c.Body()) > 0 {
+			request.Dict("body", zerolog.Dict().
+				Str("content", capturedBody(c.Body(), capture.Limit())),
+			)
+		}
+
+		header := &c.Response().Header
+		if !c.Response().IsBodyStream() && capture.CapturesContent(string(header.ContentType()), string(header.Peek(contentEncodingHeaderKey))) {
+			responseBodyDict.Str("content", capturedBody(c.Response().Body(), capture.Limit()))
+		}
+	}
+
+	response := zerolog.Dict().
+		Int("statusCode", c.Response().StatusCode()).
+		Dict("body", responseBodyDict)
+	if options.Headers.Enabled {
+		response.Dict("headers", options.Headers.Dict(collectHeaders(c.Response().Header.VisitAll)))
+	}
+
+	completed := ReqLogger(c).Info().
 		Dict("http", zerolog.Dict().
-			Dict("request", zerolog.Dict().
-				Str("method", c.Method()).
-				Dict("userAgent", zerolog.Dict().
-					Str("original", c.Get(userAgentHeaderKey)),
-				),
-			).
-			Dict("response", zerolog.Dict().
-				Int("statusCode", c.Response().StatusCode()).
-				Dict("body", zerolog.Dict().
-					Int("bytes", c.Response().Header.ContentLength()),
-				),
-			),
+			Dict("request", request).
+			Dict("response", response),
 		).
 		Dict("url", zerolog.Dict().
 			Str("path", string(c.Request().URI().RequestURI())),
-		).
+		)
+	// requests matching no route have no route field
+	if route != "" {
+		completed.Str("route", route)
+	}
+	completed.
 		Dict("host", zerolog.Dict().
 			Str("hostname", removePort(string(c.Context().Host()))).
 			Str("forwardedHost", c.Get(forwardedHostHeaderKey)).
@@ -100,20 +160,70 @@ func logCompleted(c *fiber.Ctx, start time.Time) {
 		Msg("request completed")
 }
 
+func requestDict(c *fiber.Ctx, headers zpm.HeaderLogging) *zerolog.Event {
+	dict := zerolog.Dict().
+		Str("method", c.Method()).
+		Dict("userAgent", zerolog.Dict().
+			Str("original", c.Get(userAgentHeaderKey)),
+		)
+	if headers.Enabled {
+		dict.Dict("headers", headers.Dict(collectHeaders(c.Request().Header.VisitAll)))
+	}
+	return dict
+}
+
+// collectHeaders copies the headers visited by the fasthttp visit function
+func collectHeaders(visitAll func(func(key, value []byte))) http.Header {
+	header := http.Header{}
+	visitAll(func(key, value []byte) {
+		header.Add(string(key), string(value))
+	})
+	return header
+}
+
+// capturedBody returns the first limit bytes of the body
+func capturedBody(body []byte, limit int) string {
+	if len(body) > limit {
+		body = body[:limit]
+	}
+	return string(body)
+}
+
+// getRoute returns the route recorded through middlewares.SetRoute, or the path of the last route
+// matched by the request, which is empty when it is still the route of the middleware itself
+func getRoute(c *fiber.Ctx, middlewareRoute *fiber.Route) string {
+	if route := zpm.Route(c.UserContext()); route != "" {
+		return route
+	}
+	if route := c.Route(); route != middlewareRoute {
+		return route.Path
+	}
+	return ""
+}
+
 func removePort(host string) string {
 	return strings.Split(host, ":")[0]
 }
 
-func extractRequestID(logger *zerolog.Logger, c *fiber.Ctx) string {
-	if requestID := c.Get(requestIDHeaderKey); requestID != "" {
+func extractRequestID(logger *zerolog.Logger, c *fiber.Ctx, options zpm.Options, traceID string) string {
+	if requestID, ok := options.InboundRequestID(func(key string) string { return c.Get(key) }); ok {
 		return requestID
 	}
 
-	// Generate a random uuid string. e.g. b75d24cb-ea79-4100-81c0-2d594fb8018d
-	requestID, err := uuid.NewRandom()
+	if options.TraceIDAsRequestID && traceID != "" {
+		return traceID
+	}
+
+	requestID, err := options.NewRequestID()
+	if err != nil && options.RequestIDGenerator != nil {
+		// fall back to the default generator, so that requests can still be correlated
+		logger.Warn().Object(zp.ErrorKey, zp.ErrorObject(err)).Msg("error generating request id, using the default generator")
+		requestID, err = zpm.UUIDv4()
+	}
 	if err != nil {
-		logger.Error().Stack().Err(err).Msg("error generating request id")
+		logger.Error().Object(zp.ErrorKey, zp.ErrorObject(err)).Msg("error generating request id")
+		return ""
 	}
 
-	return requestID.String()
+	return requestID
 }
@@ -19,6 +19,7 @@ package fiber
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -31,8 +32,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	zp "github.com/danibix95/zeropino"
-	pino "github.com/danibix95/zeropino/internal/model"
 	zpm "github.com/danibix95/zeropino/middlewares"
+	"github.com/danibix95/zeropino/pino"
 )
 
 const (
@@ -228,6 +229,382 @@ func TestRequestLogger(t *testing.T) {
 	})
 }
 
+func TestRequestLoggerTraceContext(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	t.Run("add the incoming trace context to the request logger", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		var stored zpm.TraceContext
+		app := fiber.New()
+		app.Use(RequestLogger(logger))
+		app.Get(requestPath, func(c *fiber.Ctx) error {
+			stored, _ = zpm.GetTraceContext(c.UserContext())
+			return c.SendStatus(fiber.StatusNoContent)
+		})
+
+		request := getRequestWithHeaders(method, defaultRequestURL, nil)
+		request.Header.Set(zpm.TraceParentHeaderKey, traceParent)
+		request.Header.Set(zpm.TraceStateHeaderKey, "vendor=value")
+
+		response, err := app.Test(request, requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		require.Equal(t, zpm.TraceContext{
+			TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:  "00f067aa0ba902b7",
+			Sampled: true,
+			State:   "vendor=value",
+		}, stored)
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, requestID, logOutput.RequestID)
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", logOutput.TraceID)
+		require.Equal(t, "00f067aa0ba902b7", logOutput.SpanID)
+		require.True(t, logOutput.TraceSampled)
+	})
+
+	t.Run("generate a trace context used as request ID when missing", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		var generated zpm.TraceContext
+		var traceParentHeader, traceStateHeader string
+		app := fiber.New()
+		app.Use(RequestLoggerWithOptions(logger, zpm.Options{TraceIDAsRequestID: true}))
+		app.Get(requestPath, func(c *fiber.Ctx) error {
+			generated, _ = zpm.GetTraceContext(c.UserContext())
+			traceParentHeader = c.Get(zpm.TraceParentHeaderKey)
+			traceStateHeader = c.Get(zpm.TraceStateHeaderKey)
+			return c.SendStatus(fiber.StatusNoContent)
+		})
+
+		request := httptest.NewRequest(method, defaultRequestURL, nil)
+		request.Header.Set(zpm.TraceStateHeaderKey, "vendor=value")
+		response, err := app.Test(request, requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		_, ok := zpm.ParseTraceParent(generated.TraceParent())
+		require.True(t, ok, "A valid trace context is stored in the user context")
+		require.Empty(t, traceParentHeader, "The request headers are not modified")
+		require.Equal(t, "vendor=value", traceStateHeader)
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, generated.TraceID, logOutput.TraceID)
+		require.Equal(t, generated.TraceID, logOutput.RequestID)
+		require.Equal(t, generated.SpanID, logOutput.SpanID)
+		require.False(t, logOutput.TraceSampled)
+	})
+
+	t.Run("ignore the trace context when disabled", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{DisableTraceContext: true, TraceIDAsRequestID: true})
+		app := createFiberApp(t, middleware, fiber.StatusOK, noContentLength)
+
+		request := httptest.NewRequest(method, defaultRequestURL, nil)
+		request.Header.Set(zpm.TraceParentHeaderKey, traceParent)
+
+		response, err := app.Test(request, requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Empty(t, logOutput.TraceID)
+		require.Empty(t, logOutput.SpanID)
+		require.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", logOutput.RequestID)
+		require.NotEmpty(t, logOutput.RequestID)
+	})
+
+	t.Run("skip logging requests with excluded prefixes", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{ExcludedPrefixes: []string{requestPath}})
+		app := createFiberApp(t, middleware, fiber.StatusOK, noContentLength)
+
+		response, err := app.Test(getRequestWithHeaders(method, defaultRequestURL, nil), requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		require.Equal(t, 0, buffer.Len(), "no log output should be produced")
+	})
+}
+
+func TestRequestLoggerRequestID(t *testing.T) {
+	t.Run("set the inbound request ID on the response", func(t *testing.T) {
+		logger, _ := zp.Init(zp.InitOptions{Writer: io.Discard})
+		app := createFiberApp(t, RequestLogger(logger), fiber.StatusOK, noContentLength)
+
+		response, err := app.Test(getRequestWithHeaders(method, defaultRequestURL, nil), requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		require.Equal(t, requestID, response.Header.Get(zpm.RequestIDHeaderKey))
+	})
+
+	t.Run("read the request ID from the configured headers", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{
+			RequestIDHeaders:   []string{"X-Correlation-ID", zpm.RequestIDHeaderKey},
+			RequestIDGenerator: func() (string, error) { return "generated-id", nil },
+		})
+		app := createFiberApp(t, middleware, fiber.StatusOK, noContentLength)
+
+		request := getRequestWithHeaders(method, defaultRequestURL, nil)
+		request.Header.Set("X-Correlation-ID", "correlation-id")
+		response, err := app.Test(request, requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		require.Equal(t, "correlation-id", response.Header.Get("X-Correlation-ID"))
+		require.Empty(t, response.Header.Get(zpm.RequestIDHeaderKey))
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, "correlation-id", logOutput.RequestID)
+	})
+
+	t.Run("generate the request ID when the inbound one is not valid", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{
+			RequestIDGenerator: func() (string, error) { return "generated-id", nil },
+		})
+		app := createFiberApp(t, middleware, fiber.StatusOK, noContentLength)
+
+		request := getRequestWithHeaders(method, defaultRequestURL, nil)
+		request.Header.Set(zpm.RequestIDHeaderKey, strings.Repeat("x", zpm.DefaultMaxRequestIDLength+1))
+		response, err := app.Test(request, requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		require.Equal(t, "generated-id", response.Header.Get(zpm.RequestIDHeaderKey))
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, "generated-id", logOutput.RequestID)
+	})
+
+	t.Run("do not set the request ID on the response when disabled", func(t *testing.T) {
+		logger, _ := zp.Init(zp.InitOptions{Writer: io.Discard})
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{DisableRequestIDResponseHeader: true})
+		app := createFiberApp(t, middleware, fiber.StatusOK, noContentLength)
+
+		response, err := app.Test(getRequestWithHeaders(method, defaultRequestURL, nil), requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		require.Empty(t, response.Header.Get(zpm.RequestIDHeaderKey))
+	})
+
+	t.Run("fall back to the default generator when the configured one fails", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{
+			RequestIDGenerator: func() (string, error) { return "", errors.New("no entropy") },
+		})
+		app := createFiberApp(t, middleware, fiber.StatusOK, noContentLength)
+
+		response, err := app.Test(httptest.NewRequest(method, defaultRequestURL, nil), requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		requestID := response.Header.Get(zpm.RequestIDHeaderKey)
+		require.Len(t, requestID, 36)
+		require.Contains(t, buffer.String(), "no entropy")
+
+		lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &logOutput))
+		require.Equal(t, requestID, logOutput.RequestID)
+	})
+}
+
+func TestRequestLoggerBodyCapture(t *testing.T) {
+	createApp := func(middleware func(*fiber.Ctx) error) *fiber.App {
+		app := fiber.New()
+		app.Use(middleware)
+		app.Post(requestPath, func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload " + string(c.Body())})
+		})
+		return app
+	}
+
+	t.Run("log request and response bodies up to the limit", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		app := createApp(RequestLoggerWithOptions(logger, zpm.Options{
+			BodyCapture: zpm.BodyCapture{Enabled: true, MaxBytes: 20, OnlyErrors: true},
+		}))
+
+		request := getRequestWithHeaders(http.MethodPost, defaultRequestURL, strings.NewReader("name=frodo&ring=one"))
+		request.Header.Set(contentTypeHeaderKey, "application/x-www-form-urlencoded")
+		response, err := app.Test(request, requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, "name=frodo&ring=one", logOutput.HTTP.Request.Body["content"])
+		require.Equal(t, `{"error":"invalid pa`, logOutput.HTTP.Response.Body["content"])
+	})
+
+	t.Run("skip bodies of not allowed content types and successful requests", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		app := createApp(RequestLoggerWithOptions(logger, zpm.Options{
+			BodyCapture: zpm.BodyCapture{Enabled: true},
+		}))
+
+		request := getRequestWithHeaders(http.MethodPost, defaultRequestURL, strings.NewReader("binary"))
+		request.Header.Set(contentTypeHeaderKey, "application/octet-stream")
+		response, err := app.Test(request, requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Nil(t, logOutput.HTTP.Request.Body)
+		require.Equal(t, `{"error":"invalid payload binary"}`, logOutput.HTTP.Response.Body["content"])
+
+		buffer.Reset()
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{
+			BodyCapture: zpm.BodyCapture{Enabled: true, OnlyErrors: true},
+		})
+		response, err = createFiberApp(t, middleware, fiber.StatusOK, noContentLength).
+			Test(getRequestWithHeaders(method, defaultRequestURL, nil), requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		logOutput = zpm.LogFormat{}
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.NotContains(t, logOutput.HTTP.Response.Body, "content")
+	})
+
+	t.Run("skip streamed response bodies", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		app := fiber.New()
+		app.Use(RequestLoggerWithOptions(logger, zpm.Options{BodyCapture: zpm.BodyCapture{Enabled: true}}))
+		app.Get(requestPath, func(c *fiber.Ctx) error {
+			c.Set(contentTypeHeaderKey, "text/event-stream")
+			c.Context().SetBodyStream(strings.NewReader("data: ring found\n\n"), -1)
+			return nil
+		})
+
+		response, err := app.Test(httptest.NewRequest(method, requestPath, nil), requestTimeoutMs)
+		require.Nil(t, err)
+		defer response.Body.Close()
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		require.Equal(t, "data: ring found\n\n", string(body), "The stream is not consumed by the logger")
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.NotContains(t, logOutput.HTTP.Response.Body, "content")
+	})
+}
+
+func TestRequestLoggerHeaders(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger, _ := zp.Init(zp.InitOptions{Level: "trace", Writer: buffer})
+
+	app := fiber.New()
+	app.Use(RequestLoggerWithOptions(logger, zpm.Options{
+		Headers: zpm.HeaderLogging{
+			Enabled: true,
+			Allow:   []string{"Authorization", userAgentHeaderKey, zpm.RequestIDHeaderKey, "Set-Cookie"},
+		},
+	}))
+	app.Get(requestPath, func(c *fiber.Ctx) error {
+		c.Cookie(&fiber.Cookie{Name: "session", Value: "secret"})
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	request := getRequestWithHeaders(method, defaultRequestURL, nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	response, err := app.Test(request, requestTimeoutMs)
+	require.Nil(t, err)
+	response.Body.Close()
+
+	entries := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	require.Equal(t, 2, len(entries))
+
+	expectedRequestHeaders := map[string]string{
+		"authorization": zpm.MaskedHeaderValue,
+		"user-agent":    userAgent,
+		"x-request-id":  requestID,
+	}
+
+	var incoming zpm.LogFormat
+	require.NoError(t, json.Unmarshal([]byte(entries[0]), &incoming))
+	require.Equal(t, expectedRequestHeaders, incoming.HTTP.Request.Headers)
+
+	var completed zpm.LogFormat
+	require.NoError(t, json.Unmarshal([]byte(entries[1]), &completed))
+	require.Equal(t, expectedRequestHeaders, completed.HTTP.Request.Headers)
+	require.Equal(t, map[string]string{
+		"set-cookie":   zpm.MaskedHeaderValue,
+		"x-request-id": requestID,
+	}, completed.HTTP.Response.Headers)
+}
+
+func TestRequestLoggerRoute(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+	app := fiber.New()
+	app.Use(RequestLogger(logger))
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	app.Get("/files/*", func(c *fiber.Ctx) error {
+		require.True(t, zpm.SetRoute(c.UserContext(), "/files/{path...}"))
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/users/123", expected: "/users/:id"},
+		{path: "/files/a/b", expected: "/files/{path...}"},
+		{path: "/unknown", expected: ""},
+	}
+
+	for _, testCase := range testCases {
+		buffer.Reset()
+
+		response, err := app.Test(httptest.NewRequest(method, testCase.path, nil), requestTimeoutMs)
+		require.Nil(t, err)
+		response.Body.Close()
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, testCase.path, logOutput.URL.Path)
+		require.Equal(t, testCase.expected, logOutput.Route, testCase.path)
+		if testCase.expected == "" {
+			require.NotContains(t, buffer.String(), `"route"`)
+		}
+	}
+}
+
 func BenchmarkRequestLogger(b *testing.B) {
 	buffer := bytes.Buffer{}
 	logger, _ := zp.Init(zp.InitOptions{Level: "trace", Writer: &buffer})
@@ -282,7 +659,7 @@ func assertResponseLog(t testing.TB, expected logFields, actual *bytes.Buffer) {
 func getRequestWithHeaders(method, path string, body io.Reader) *http.Request {
 	request := httptest.NewRequest(method, path, body)
 	ip := removePort(request.RemoteAddr)
-	request.Header.Set(requestIDHeaderKey, requestID)
+	request.Header.Set(zpm.RequestIDHeaderKey, requestID)
 	request.Header.Set(userAgentHeaderKey, userAgent)
 	request.Header.Set(forwardedForHeaderKey, ip)
 	request.Header.Set(forwardedHostHeaderKey, clientHost)
@@ -0,0 +1,82 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// MaskedHeaderValue replaces the value of the credential headers that are logged
+const MaskedHeaderValue = "[Redacted]"
+
+// credentialHeaders are the headers whose values are masked unless explicitly unmasked
+var credentialHeaders = []string{"authorization", "cookie", "set-cookie", "x-api-key", "proxy-authorization"}
+
+// HeaderLogging configures the logging of request and response headers
+type HeaderLogging struct {
+	// Enabled logs the headers under http.request.headers and http.response.headers fields,
+	// where header names are lowercase and the values of repeated headers are joined by commas
+	Enabled bool
+	// Allow lists the names of the headers to log, all of them when empty
+	Allow []string
+	// Deny lists the names of the headers never logged, even when allowed
+	Deny []string
+	// Unmasked lists the credential headers logged in clear, i.e. Authorization, Cookie,
+	// Set-Cookie, X-Api-Key and Proxy-Authorization, whose values are masked otherwise
+	Unmasked []string
+}
+
+// Dict returns the logged headers as a zerolog dictionary, sorted by name
+func (h HeaderLogging) Dict(headers http.Header) *zerolog.Event {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dict := zerolog.Dict()
+	for _, name := range names {
+		if !h.logs(name) {
+			continue
+		}
+
+		value := strings.Join(headers[name], ", ")
+		if containsFold(credentialHeaders, name) && !containsFold(h.Unmasked, name) {
+			value = MaskedHeaderValue
+		}
+		dict.Str(strings.ToLower(name), value)
+	}
+	return dict
+}
+
+// logs reports whether the header with the given name is logged
+func (h HeaderLogging) logs(name string) bool {
+	return (len(h.Allow) == 0 || containsFold(h.Allow, name)) && !containsFold(h.Deny, name)
+}
+
+func containsFold(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(candidate, name) {
+			return true
+		}
+	}
+	return false
+}
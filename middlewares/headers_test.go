@@ -0,0 +1,70 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderLoggingDict(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer secret")
+	headers.Set("X-Api-Key", "key")
+	headers.Add("Accept", "text/plain")
+	headers.Add("Accept", "application/json")
+	headers.Set("Cookie", "session=secret")
+
+	testCases := []struct {
+		name     string
+		options  HeaderLogging
+		expected string
+	}{
+		{
+			name:     "mask credentials",
+			options:  HeaderLogging{Enabled: true},
+			expected: `{"headers":{"accept":"text/plain, application/json","authorization":"[Redacted]","cookie":"[Redacted]","x-api-key":"[Redacted]"}}`,
+		},
+		{
+			name:     "log only allowed headers",
+			options:  HeaderLogging{Enabled: true, Allow: []string{"accept", "X-API-KEY"}},
+			expected: `{"headers":{"accept":"text/plain, application/json","x-api-key":"[Redacted]"}}`,
+		},
+		{
+			name:     "skip denied headers",
+			options:  HeaderLogging{Enabled: true, Allow: []string{"Accept", "Cookie"}, Deny: []string{"cookie"}},
+			expected: `{"headers":{"accept":"text/plain, application/json"}}`,
+		},
+		{
+			name:     "log unmasked credentials",
+			options:  HeaderLogging{Enabled: true, Allow: []string{"Authorization"}, Unmasked: []string{"authorization"}},
+			expected: `{"headers":{"authorization":"Bearer secret"}}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		buffer := &bytes.Buffer{}
+		logger := zerolog.New(buffer)
+		logger.Log().Dict("headers", testCase.options.Dict(headers)).Send()
+
+		require.JSONEq(t, testCase.expected, buffer.String(), testCase.name)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// RequestIDHeaderKey is the header carrying the request ID when no other is configured
+	RequestIDHeaderKey = "X-Request-ID"
+	// DefaultMaxRequestIDLength is the length above which inbound request IDs are discarded
+	DefaultMaxRequestIDLength = 128
+)
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// RequestIDGenerator generates the ID of the requests that do not carry a valid one
+type RequestIDGenerator func() (string, error)
+
+// UUIDv4 generates a random UUID, e.g. 16c9c1f2-c001-40d3-bbfe-48857367e7b5
+func UUIDv4() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// UUIDv7 generates a UUID ordered by creation time, e.g. 0190163d-8694-739b-aea5-966c26f8ad91
+func UUIDv7() (string, error) {
+	return newUUIDv7(time.Now())
+}
+
+func newUUIDv7(now time.Time) (string, error) {
+	var id uuid.UUID
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+
+	putMillis(id[:6], now)
+	id[6] = id[6]&0x0f | 0x70
+	id[8] = id[8]&0x3f | 0x80
+	return id.String(), nil
+}
+
+// ULID generates a lexicographically sortable identifier, e.g. 01J0B3V1MMD7ZTKW1M9DDT5G3E
+func ULID() (string, error) {
+	return newULID(time.Now())
+}
+
+func newULID(now time.Time) (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+	putMillis(id[:6], now)
+
+	// encode the 128 bits of the ID as 26 Crockford base32 characters
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	var encoded [26]byte
+	for i := len(encoded) - 1; i >= 0; i-- {
+		encoded[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(encoded[:]), nil
+}
+
+// putMillis writes the 48 bits Unix time in milliseconds into dst
+func putMillis(dst []byte, t time.Time) {
+	var buffer [8]byte
+	binary.BigEndian.PutUint64(buffer[:], uint64(t.UnixMilli()))
+	copy(dst, buffer[2:])
+}
+
+// InboundRequestID returns the first valid request ID found in the configured request ID headers,
+// read through get, reporting whether there is one
+func (o Options) InboundRequestID(get func(key string) string) (string, bool) {
+	maxLength := o.MaxRequestIDLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxRequestIDLength
+	}
+
+	for _, header := range o.requestIDHeaders() {
+		if requestID := get(header); requestID != "" && len(requestID) <= maxLength && isPrintable(requestID) {
+			return requestID, true
+		}
+	}
+	return "", false
+}
+
+// NewRequestID generates a request ID with the configured generator, UUIDv4 by default
+func (o Options) NewRequestID() (string, error) {
+	if o.RequestIDGenerator == nil {
+		return UUIDv4()
+	}
+	return o.RequestIDGenerator()
+}
+
+// ResponseRequestIDHeader returns the response header set to the request ID,
+// which is the first request ID header, or an empty string when disabled
+func (o Options) ResponseRequestIDHeader() string {
+	if o.DisableRequestIDResponseHeader {
+		return ""
+	}
+	return o.requestIDHeaders()[0]
+}
+
+func (o Options) requestIDHeaders() []string {
+	if len(o.RequestIDHeaders) == 0 {
+		return []string{RequestIDHeaderKey}
+	}
+	return o.RequestIDHeaders
+}
+
+// isPrintable reports whether value contains only printable ASCII characters,
+// so that it can be safely logged and sent back as header
+func isPrintable(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < ' ' || value[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
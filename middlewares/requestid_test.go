@@ -0,0 +1,107 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDGenerators(t *testing.T) {
+	testCases := []struct {
+		name      string
+		generator RequestIDGenerator
+		pattern   string
+	}{
+		{name: "UUIDv4", generator: UUIDv4, pattern: "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$"},
+		{name: "UUIDv7", generator: UUIDv7, pattern: "^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$"},
+		{name: "ULID", generator: ULID, pattern: "^[0-7][0-9A-HJKMNP-TV-Z]{25}$"},
+	}
+
+	for _, testCase := range testCases {
+		first, err := testCase.generator()
+		require.NoError(t, err, testCase.name)
+		second, err := testCase.generator()
+		require.NoError(t, err, testCase.name)
+
+		require.Regexp(t, regexp.MustCompile(testCase.pattern), first, testCase.name)
+		require.NotEqual(t, first, second, testCase.name)
+	}
+}
+
+func TestTimeOrderedRequestIDs(t *testing.T) {
+	now := time.UnixMilli(1469922850259)
+
+	id, err := newULID(now)
+	require.NoError(t, err)
+	require.Equal(t, "01ARZ3NDEK", id[:10], "ULID starts with the encoded time")
+
+	for _, generator := range []func(time.Time) (string, error){newULID, newUUIDv7} {
+		first, _ := generator(now)
+		second, _ := generator(now.Add(time.Millisecond))
+		require.Less(t, first, second, "IDs are ordered by creation time")
+	}
+}
+
+func TestInboundRequestID(t *testing.T) {
+	headers := map[string]string{
+		"X-Request-ID":     "",
+		"X-Correlation-ID": "correlation-id",
+		"X-Trace":          "trace",
+	}
+	get := func(key string) string { return headers[key] }
+
+	requestID, ok := Options{}.InboundRequestID(get)
+	require.False(t, ok)
+	require.Empty(t, requestID)
+
+	options := Options{RequestIDHeaders: []string{"X-Request-ID", "X-Correlation-ID", "X-Trace"}}
+	requestID, ok = options.InboundRequestID(get)
+	require.True(t, ok)
+	require.Equal(t, "correlation-id", requestID, "Headers are checked in order")
+	require.Equal(t, "X-Request-ID", options.ResponseRequestIDHeader())
+
+	headers["X-Correlation-ID"] = "new\nline\x7f"
+	requestID, _ = options.InboundRequestID(get)
+	require.Equal(t, "trace", requestID, "Non printable request IDs are discarded")
+
+	headers["X-Correlation-ID"] = strings.Repeat("a", DefaultMaxRequestIDLength+1)
+	requestID, _ = options.InboundRequestID(get)
+	require.Equal(t, "trace", requestID, "Oversized request IDs are discarded")
+
+	options.MaxRequestIDLength = 4
+	headers["X-Correlation-ID"] = "abcd"
+	requestID, _ = options.InboundRequestID(get)
+	require.Equal(t, "abcd", requestID)
+
+	options.DisableRequestIDResponseHeader = true
+	require.Empty(t, options.ResponseRequestIDHeader())
+}
+
+func TestNewRequestID(t *testing.T) {
+	requestID, err := Options{}.NewRequestID()
+	require.NoError(t, err)
+	require.Len(t, requestID, 36)
+
+	requestID, err = Options{RequestIDGenerator: func() (string, error) { return "custom", nil }}.NewRequestID()
+	require.NoError(t, err)
+	require.Equal(t, "custom", requestID)
+}
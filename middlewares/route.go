@@ -0,0 +1,56 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type routeKey struct{}
+
+// WithRouteCapture returns a new context where routers can record, through SetRoute,
+// the route matched by the request, as zeropino middlewares do before calling the handler
+func WithRouteCapture(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routeKey{}, &atomic.Pointer[string]{})
+}
+
+// SetRoute records the route template matched by the request, e.g. /users/{id}, so that
+// zeropino middlewares log it once the request is completed. It reports whether the
+// context allows to record the route, i.e. it is derived from WithRouteCapture.
+func SetRoute(ctx context.Context, route string) bool {
+	holder, ok := ctx.Value(routeKey{}).(*atomic.Pointer[string])
+	if !ok {
+		return false
+	}
+
+	holder.Store(&route)
+	return true
+}
+
+// Route returns the route recorded in the context by SetRoute, or an empty string when there is none
+func Route(ctx context.Context) string {
+	holder, ok := ctx.Value(routeKey{}).(*atomic.Pointer[string])
+	if !ok {
+		return ""
+	}
+
+	if route := holder.Load(); route != nil {
+		return *route
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoute(t *testing.T) {
+	require.False(t, SetRoute(context.Background(), "/users/{id}"), "The route is not recorded without capture")
+	require.Empty(t, Route(context.Background()))
+
+	ctx := WithRouteCapture(context.Background())
+	require.Empty(t, Route(ctx))
+
+	// the route is recorded by handlers on contexts derived from the captured one
+	handlerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	require.True(t, SetRoute(handlerCtx, "/users/{id}"))
+	require.Equal(t, "/users/{id}", Route(ctx))
+}
@@ -18,6 +18,7 @@ package std
 
 import (
 	"context"
+	"sync"
 
 	"github.com/rs/zerolog"
 
@@ -26,7 +27,19 @@ import (
 
 type loggerKey struct{}
 
-var defaultLogger *zerolog.Logger = zp.InitDefault()
+var (
+	defaultLoggerOnce sync.Once
+	defaultLogger     *zerolog.Logger
+)
+
+// getDefaultLogger lazily creates the default logger, so that importing
+// this package does not have any side effect
+func getDefaultLogger() *zerolog.Logger {
+	defaultLoggerOnce.Do(func() {
+		defaultLogger = zp.InitDefault()
+	})
+	return defaultLogger
+}
 
 // WithLogger returns a new context with the provided logger. Use in
 // combination with logger.WithField(s) for great effect.
@@ -40,12 +53,12 @@ func Get(ctx context.Context) *zerolog.Logger {
 	logger := ctx.Value(loggerKey{})
 
 	if logger == nil {
-		return defaultLogger
+		return getDefaultLogger()
 	}
 
 	entry, ok := logger.(*zerolog.Logger)
 	if !ok {
-		return defaultLogger
+		return getDefaultLogger()
 	}
 	return entry
 }
@@ -18,61 +18,106 @@ package std
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+
+	zp "github.com/danibix95/zeropino"
+	zpm "github.com/danibix95/zeropino/middlewares"
 )
 
 const million float64 = 1000000
 const (
-	contentLengthHeaderKey = "Content-Length"
-	requestIDHeaderKey     = "X-Request-ID"
-	forwardedHostHeaderKey = "X-Forwarded-Host"
-	forwardedForHeaderKey  = "X-Forwarded-For"
+	contentLengthHeaderKey   = "Content-Length"
+	contentTypeHeaderKey     = "Content-Type"
+	contentEncodingHeaderKey = "Content-Encoding"
+	forwardedHostHeaderKey   = "X-Forwarded-Host"
+	forwardedForHeaderKey    = "X-Forwarded-For"
 )
 
 // RequestLogger is a gorilla/mux middleware to log all requests with zeropino
 // It logs the incoming request and when request is completed, adding latency of the request
 func RequestLogger(logger *zerolog.Logger, excludedPrefix []string) func(next http.Handler) http.Handler {
+	return RequestLoggerWithOptions(logger, zpm.Options{ExcludedPrefixes: excludedPrefix})
+}
+
+// RequestLoggerWithOptions is the RequestLogger middleware configured by the provided options.
+// The request ID is read from the configured headers, generated when none is valid, and
+// set on the response. Unless disabled, the W3C trace context of each request is added to
+// its logger and stored in the request context, generating a new one when traceparent
+// header is missing or invalid. When body capture is enabled, the beginning of the request
+// body read by the handler and of the response body are logged once the request is completed.
+// The completed request log contains also the route matched by the request, which is the
+// http.ServeMux pattern or the one recorded by other routers through middlewares.SetRoute.
+func RequestLoggerWithOptions(logger *zerolog.Logger, options zpm.Options) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
+			ctx := r.Context()
+
+			var traceContext zpm.TraceContext
+			if !options.DisableTraceContext {
+				// the request headers are left as sent by the client
+				traceContext, _ = zpm.RequestTraceContext(r.Header.Get)
+				ctx = zpm.WithTraceContext(ctx, traceContext)
+			}
+
+			requestID := getReqID(logger, r.Header, options, traceContext.TraceID)
+			if header := options.ResponseRequestIDHeader(); header != "" && requestID != "" {
+				w.Header().Set(header, requestID)
+			}
 
-			requestID := getReqID(logger, r.Header)
-			reqLogger := logger.With().Str("reqId", requestID).Logger()
-			ctx := WithLogger(r.Context(), &reqLogger)
+			loggerContext := logger.With().Str("reqId", requestID)
+			if !options.DisableTraceContext {
+				loggerContext = loggerContext.
+					Str("traceId", traceContext.TraceID).
+					Str("spanId", traceContext.SpanID).
+					Bool("traceSampled", traceContext.Sampled)
+			}
+			reqLogger := loggerContext.Logger()
+			ctx = WithLogger(zp.WithRequestID(ctx, requestID), &reqLogger)
 			customRW := readableResponseWriter{writer: w, statusCode: http.StatusOK}
 
 			// Skip logging for excluded routes
-			for _, prefix := range excludedPrefix {
+			for _, prefix := range options.ExcludedPrefixes {
 				if strings.HasPrefix(r.URL.RequestURI(), prefix) {
 					next.ServeHTTP(&customRW, r.WithContext(ctx))
 					return
 				}
 			}
 
-			logIncoming(ctx, r)
+			var requestBody *zpm.BodyBuffer
+			if options.BodyCapture.Enabled {
+				customRW.body = zpm.NewBodyBuffer(options.BodyCapture.Limit())
 
-			next.ServeHTTP(&customRW, r.WithContext(ctx))
+				contentType, contentEncoding := r.Header.Get(contentTypeHeaderKey), r.Header.Get(contentEncodingHeaderKey)
+				if r.Body != nil && r.Body != http.NoBody && options.BodyCapture.CapturesContent(contentType, contentEncoding) {
+					// the body is captured while the handler reads it
+					requestBody = zpm.NewBodyBuffer(options.BodyCapture.Limit())
+					r.Body = teeReadCloser{Reader: io.TeeReader(r.Body, requestBody), Closer: r.Body}
+				}
+			}
+
+			logIncoming(ctx, r, options.Headers)
+
+			// the ServeMux wrapped by the middleware sets the matched pattern on the request it receives
+			ctx = zpm.WithRouteCapture(ctx)
+			request := r.WithContext(ctx)
+			next.ServeHTTP(&customRW, request)
 
-			logOutgoing(ctx, r, &customRW, start)
+			logOutgoing(ctx, request, &customRW, start, options, requestBody)
 		})
 	}
 }
 
-func logIncoming(ctx context.Context, r *http.Request) {
+func logIncoming(ctx context.Context, r *http.Request, headers zpm.HeaderLogging) {
 	Get(ctx).Trace().
 		Dict("http", zerolog.Dict().
-			Dict("request", zerolog.Dict().
-				Str("method", r.Method).
-				Dict("userAgent", zerolog.Dict().
-					Str("original", r.UserAgent()),
-				),
-			),
+			Dict("request", requestLogDict(r, headers)),
 		).
 		Dict("url", zerolog.Dict().
 			Str("path", r.URL.RequestURI()),
@@ -85,25 +130,49 @@ func logIncoming(ctx context.Context, r *http.Request) {
 		Msg("incoming request")
 }
 
-func logOutgoing(ctx context.Context, r *http.Request, myw *readableResponseWriter, start time.Time) {
-	Get(ctx).Info().
+func logOutgoing(
+	ctx context.Context,
+	r *http.Request,
+	myw *readableResponseWriter,
+	start time.Time,
+	options zpm.Options,
+	requestBody *zpm.BodyBuffer) {
+	capture := options.BodyCapture
+	requestDict := requestLogDict(r, options.Headers)
+	responseBodyDict := zerolog.Dict().
+		Int("bytes", getBodyLength(myw))
+
+	if capture.CapturesStatus(myw.statusCode) {
+		if requestBody != nil {
+			requestDict.Dict("body", zerolog.Dict().
+				Str("content", requestBody.String()),
+			)
+		}
+		if myw.body != nil && capture.CapturesContent(myw.Header().Get(contentTypeHeaderKey), myw.Header().Get(contentEncodingHeaderKey)) {
+			responseBodyDict.Str("content", myw.body.String())
+		}
+	}
+
+	responseDict := zerolog.Dict().
+		Int("statusCode", myw.statusCode).
+		Dict("body", responseBodyDict)
+	if options.Headers.Enabled {
+		responseDict.Dict("headers", options.Headers.Dict(myw.Header()))
+	}
+
+	completed := Get(ctx).Info().
 		Dict("http", zerolog.Dict().
-			Dict("request", zerolog.Dict().
-				Str("method", r.Method).
-				Dict("userAgent", zerolog.Dict().
-					Str("original", r.UserAgent()),
-				),
-			).
-			Dict("response", zerolog.Dict().
-				Int("statusCode", myw.statusCode).
-				Dict("body", zerolog.Dict().
-					Int("bytes", getBodyLength(myw)),
-				),
-			),
+			Dict("request", requestDict).
+			Dict("response", responseDict),
 		).
 		Dict("url", zerolog.Dict().
 			Str("path", r.URL.RequestURI()),
-		).
+		)
+	// requests matching no route have no route field
+	if route := getRoute(ctx, r); route != "" {
+		completed.Str("route", route)
+	}
+	completed.
 		Dict("host", zerolog.Dict().
 			Str("hostname", removePort(r.Host)).
 			Str("forwardedHost", r.Header.Get(forwardedHostHeaderKey)).
@@ -113,6 +182,28 @@ func logOutgoing(ctx context.Context, r *http.Request, myw *readableResponseWrit
 		Msg("request completed")
 }
 
+func requestLogDict(r *http.Request, headers zpm.HeaderLogging) *zerolog.Event {
+	dict := requestDict(r)
+	if headers.Enabled {
+		dict.Dict("headers", headers.Dict(r.Header))
+	}
+	return dict
+}
+
+// teeReadCloser closes the original request body while reading it through a tee
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// getRoute returns the route recorded through middlewares.SetRoute, or the ServeMux pattern matched by the request
+func getRoute(ctx context.Context, r *http.Request) string {
+	if route := zpm.Route(ctx); route != "" {
+		return route
+	}
+	return r.Pattern
+}
+
 func removePort(host string) string {
 	return strings.Split(host, ":")[0]
 }
@@ -126,18 +217,25 @@ func getBodyLength(customRW *readableResponseWriter) int {
 	return customRW.Length()
 }
 
-func getReqID(logger *zerolog.Logger, headers http.Header) string {
-	if requestID := headers.Get(requestIDHeaderKey); requestID != "" {
+func getReqID(logger *zerolog.Logger, headers http.Header, options zpm.Options, traceID string) string {
+	if requestID, ok := options.InboundRequestID(headers.Get); ok {
 		return requestID
 	}
 
-	// Generate a random uuid string. e.g. 16c9c1f2-c001-40d3-bbfe-48857367e7b5
-	requestIDRaw, err := uuid.NewRandom()
-	if err != nil {
-		logger.Error().Stack().Err(err).Msg("error generating request id")
+	if options.TraceIDAsRequestID && traceID != "" {
+		return traceID
 	}
 
-	requestID := requestIDRaw.String()
+	requestID, err := options.NewRequestID()
+	if err != nil && options.RequestIDGenerator != nil {
+		// fall back to the default generator, so that requests can still be correlated
+		logger.Warn().Object(zp.ErrorKey, zp.ErrorObject(err)).Msg("error generating request id, using the default generator")
+		requestID, err = zpm.UUIDv4()
+	}
+	if err != nil {
+		logger.Error().Object(zp.ErrorKey, zp.ErrorObject(err)).Msg("error generating request id")
+		return ""
+	}
 	logger.Trace().Str("reqId", requestID).Msg("generated request id")
 
 	return requestID
@@ -19,19 +19,22 @@ package std
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
 	zp "github.com/danibix95/zeropino"
-	pino "github.com/danibix95/zeropino/internal/model"
 	zpm "github.com/danibix95/zeropino/middlewares"
+	"github.com/danibix95/zeropino/pino"
 )
 
 const hostname = "my-host"
@@ -192,6 +195,46 @@ func TestRequestLogger(t *testing.T) {
 		require.Equal(t, 0, buffer.Len(), "no log output should be produced")
 	})
 
+	t.Run("atomic level changes apply to request loggers", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		level := zp.NewAtomicLevel()
+		logger, _ := zp.Init(zp.InitOptions{Level: "warn", Writer: buffer, AtomicLevel: level})
+
+		middleware := RequestLogger(logger, []string{"/-/"})
+		app := createHTTPServer(t, middleware, http.StatusOK, false)
+
+		app.ServeHTTP(httptest.NewRecorder(), getRequestWithHeaders(method, defaultRequestURL, nil))
+		require.Equal(t, 0, buffer.Len(), "no log output should be produced")
+
+		level.SetLevel(zerolog.TraceLevel)
+
+		app.ServeHTTP(httptest.NewRecorder(), getRequestWithHeaders(method, defaultRequestURL, nil))
+		entries := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		require.Equal(t, 2, len(entries))
+	})
+
+	t.Run("slog records written in handlers carry the request ID", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Level: "info", Writer: buffer})
+		slogger := slog.New(zp.NewSlogHandler(logger))
+
+		middleware := RequestLogger(logger, []string{"/-/"})
+		handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, requestID, zp.RequestID(r.Context()))
+			slogger.InfoContext(r.Context(), "handling request")
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), getRequestWithHeaders(method, defaultRequestURL, nil))
+
+		entries := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		require.Equal(t, 2, len(entries))
+
+		var entry zpm.LogFormat
+		require.NoError(t, json.Unmarshal([]byte(entries[0]), &entry))
+		require.Equal(t, "handling request", entry.Msg)
+		require.Equal(t, requestID, entry.RequestID)
+	})
+
 	t.Run("skip logging certain routes", func(t *testing.T) {
 		buffer := &bytes.Buffer{}
 		logger, _ := zp.Init(zp.InitOptions{Level: "trace", Writer: buffer})
@@ -215,7 +258,7 @@ func TestRequestLogger(t *testing.T) {
 		router.Handle(healthzPath, middleware(http.HandlerFunc(healthHandler)))
 
 		request := httptest.NewRequest(method, fmt.Sprintf("http://%s:3000%s", hostname, healthzPath), nil)
-		request.Header.Set(requestIDHeaderKey, requestID)
+		request.Header.Set(zpm.RequestIDHeaderKey, requestID)
 
 		recorder := httptest.NewRecorder()
 		router.ServeHTTP(recorder, request)
@@ -225,6 +268,324 @@ func TestRequestLogger(t *testing.T) {
 	})
 }
 
+func TestRequestLoggerTraceContext(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	t.Run("add the incoming trace context to the request logger", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		var stored zpm.TraceContext
+		handler := RequestLogger(logger, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			stored, _ = zpm.GetTraceContext(r.Context())
+		}))
+
+		request := getRequestWithHeaders(method, defaultRequestURL, nil)
+		request.Header.Set(zpm.TraceParentHeaderKey, traceParent)
+		request.Header.Set(zpm.TraceStateHeaderKey, "vendor=value")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		require.Equal(t, zpm.TraceContext{
+			TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:  "00f067aa0ba902b7",
+			Sampled: true,
+			State:   "vendor=value",
+		}, stored)
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, requestID, logOutput.RequestID)
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", logOutput.TraceID)
+		require.Equal(t, "00f067aa0ba902b7", logOutput.SpanID)
+		require.True(t, logOutput.TraceSampled)
+	})
+
+	t.Run("generate a trace context used as request ID when missing", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		var generated zpm.TraceContext
+		var traceParentHeader string
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{TraceIDAsRequestID: true})
+		handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			generated, _ = zpm.GetTraceContext(r.Context())
+			traceParentHeader = r.Header.Get(zpm.TraceParentHeaderKey)
+		}))
+
+		request := httptest.NewRequest(method, defaultRequestURL, nil)
+		request.Header.Set(zpm.TraceStateHeaderKey, "vendor=value")
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		_, ok := zpm.ParseTraceParent(generated.TraceParent())
+		require.True(t, ok, "A valid trace context is stored in the request context")
+		require.Empty(t, traceParentHeader, "The request headers are not modified")
+		require.Equal(t, "vendor=value", request.Header.Get(zpm.TraceStateHeaderKey))
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, generated.TraceID, logOutput.TraceID)
+		require.Equal(t, generated.TraceID, logOutput.RequestID)
+		require.Equal(t, generated.SpanID, logOutput.SpanID)
+		require.False(t, logOutput.TraceSampled)
+	})
+
+	t.Run("ignore the trace context when disabled", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{DisableTraceContext: true, TraceIDAsRequestID: true})
+		handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, ok := zpm.GetTraceContext(r.Context())
+			require.False(t, ok)
+		}))
+
+		request := httptest.NewRequest(method, defaultRequestURL, nil)
+		request.Header.Set(zpm.TraceParentHeaderKey, traceParent)
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Empty(t, logOutput.TraceID)
+		require.Empty(t, logOutput.SpanID)
+		require.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", logOutput.RequestID)
+		require.NotEmpty(t, logOutput.RequestID)
+	})
+}
+
+func TestRequestLoggerRequestID(t *testing.T) {
+	t.Run("set the inbound request ID on the response", func(t *testing.T) {
+		logger, _ := zp.Init(zp.InitOptions{Writer: io.Discard})
+		app := createHTTPServer(t, RequestLogger(logger, nil), http.StatusOK, false)
+
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, getRequestWithHeaders(method, defaultRequestURL, nil))
+
+		require.Equal(t, requestID, recorder.Header().Get(zpm.RequestIDHeaderKey))
+	})
+
+	t.Run("read the request ID from the configured headers", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{
+			RequestIDHeaders:   []string{"X-Correlation-ID", zpm.RequestIDHeaderKey},
+			RequestIDGenerator: func() (string, error) { return "generated-id", nil },
+		})
+		app := createHTTPServer(t, middleware, http.StatusOK, false)
+
+		request := getRequestWithHeaders(method, defaultRequestURL, nil)
+		request.Header.Set("X-Correlation-ID", "correlation-id")
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, request)
+
+		require.Equal(t, "correlation-id", recorder.Header().Get("X-Correlation-ID"))
+		require.Empty(t, recorder.Header().Get(zpm.RequestIDHeaderKey))
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, "correlation-id", logOutput.RequestID)
+	})
+
+	t.Run("generate the request ID when the inbound one is not valid", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{
+			RequestIDGenerator: func() (string, error) { return "generated-id", nil },
+		})
+		app := createHTTPServer(t, middleware, http.StatusOK, false)
+
+		request := getRequestWithHeaders(method, defaultRequestURL, nil)
+		request.Header.Set(zpm.RequestIDHeaderKey, strings.Repeat("x", zpm.DefaultMaxRequestIDLength+1))
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, request)
+
+		require.Equal(t, "generated-id", recorder.Header().Get(zpm.RequestIDHeaderKey))
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, "generated-id", logOutput.RequestID)
+	})
+
+	t.Run("do not set the request ID on the response when disabled", func(t *testing.T) {
+		logger, _ := zp.Init(zp.InitOptions{Writer: io.Discard})
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{DisableRequestIDResponseHeader: true})
+		app := createHTTPServer(t, middleware, http.StatusOK, false)
+
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, getRequestWithHeaders(method, defaultRequestURL, nil))
+
+		require.Empty(t, recorder.Header().Get(zpm.RequestIDHeaderKey))
+	})
+
+	t.Run("fall back to the default generator when the configured one fails", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{
+			RequestIDGenerator: func() (string, error) { return "", errors.New("no entropy") },
+		})
+		app := createHTTPServer(t, middleware, http.StatusOK, false)
+
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, httptest.NewRequest(method, defaultRequestURL, nil))
+
+		requestID := recorder.Header().Get(zpm.RequestIDHeaderKey)
+		require.Len(t, requestID, 36)
+		require.Contains(t, buffer.String(), "no entropy")
+
+		lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &logOutput))
+		require.Equal(t, requestID, logOutput.RequestID)
+	})
+}
+
+func TestRequestLoggerBodyCapture(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		w.Header().Set(contentTypeHeaderKey, "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error":"invalid payload %s"}`, payload)
+	})
+
+	t.Run("log request and response bodies up to the limit", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{
+			BodyCapture: zpm.BodyCapture{Enabled: true, MaxBytes: 20, OnlyErrors: true},
+		})
+
+		request := getRequestWithHeaders(http.MethodPost, defaultRequestURL, strings.NewReader("name=frodo&ring=one"))
+		request.Header.Set(contentTypeHeaderKey, "application/x-www-form-urlencoded")
+		middleware(handler).ServeHTTP(httptest.NewRecorder(), request)
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, "name=frodo&ring=one", logOutput.HTTP.Request.Body["content"])
+		require.Equal(t, `{"error":"invalid pa`, logOutput.HTTP.Response.Body["content"])
+		require.EqualValues(t, len(`{"error":"invalid payload name=frodo&ring=one"}`), logOutput.HTTP.Response.Body["bytes"])
+	})
+
+	t.Run("skip bodies of not allowed content types and successful requests", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		middleware := RequestLoggerWithOptions(logger, zpm.Options{
+			BodyCapture: zpm.BodyCapture{Enabled: true},
+		})
+
+		request := getRequestWithHeaders(http.MethodPost, defaultRequestURL, strings.NewReader("binary"))
+		request.Header.Set(contentTypeHeaderKey, "application/octet-stream")
+		middleware(handler).ServeHTTP(httptest.NewRecorder(), request)
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Nil(t, logOutput.HTTP.Request.Body)
+		require.Equal(t, `{"error":"invalid payload binary"}`, logOutput.HTTP.Response.Body["content"])
+
+		buffer.Reset()
+		middleware = RequestLoggerWithOptions(logger, zpm.Options{
+			BodyCapture: zpm.BodyCapture{Enabled: true, OnlyErrors: true},
+		})
+		app := createHTTPServer(t, middleware, http.StatusOK, false)
+		app.ServeHTTP(httptest.NewRecorder(), getRequestWithHeaders(method, defaultRequestURL, nil))
+
+		logOutput = zpm.LogFormat{}
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.NotContains(t, logOutput.HTTP.Response.Body, "content")
+	})
+}
+
+func TestRequestLoggerHeaders(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger, _ := zp.Init(zp.InitOptions{Level: "trace", Writer: buffer})
+
+	middleware := RequestLoggerWithOptions(logger, zpm.Options{
+		Headers: zpm.HeaderLogging{Enabled: true, Deny: []string{forwardedForHeaderKey}},
+	})
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := getRequestWithHeaders(method, defaultRequestURL, nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	entries := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	require.Equal(t, 2, len(entries))
+
+	expectedRequestHeaders := map[string]string{
+		"authorization":    zpm.MaskedHeaderValue,
+		"user-agent":       userAgent,
+		"x-forwarded-host": clientHost,
+		"x-request-id":     requestID,
+	}
+
+	var incoming zpm.LogFormat
+	require.NoError(t, json.Unmarshal([]byte(entries[0]), &incoming))
+	require.Equal(t, expectedRequestHeaders, incoming.HTTP.Request.Headers)
+
+	var completed zpm.LogFormat
+	require.NoError(t, json.Unmarshal([]byte(entries[1]), &completed))
+	require.Equal(t, expectedRequestHeaders, completed.HTTP.Request.Headers)
+	require.Equal(t, map[string]string{
+		"set-cookie":   zpm.MaskedHeaderValue,
+		"x-request-id": requestID,
+	}, completed.HTTP.Response.Headers)
+}
+
+func TestRequestLoggerRoute(t *testing.T) {
+	t.Run("log the ServeMux pattern matched by the request", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		router := http.NewServeMux()
+		router.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		RequestLogger(logger, nil)(router).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/users/123", nil))
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, "/users/123", logOutput.URL.Path)
+		require.Equal(t, "GET /users/{id}", logOutput.Route)
+	})
+
+	t.Run("log the route recorded by other routers", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		handler := RequestLogger(logger, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.True(t, zpm.SetRoute(r.Context(), "/users/{id}"))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/users/123", nil))
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, "/users/{id}", logOutput.Route)
+	})
+
+	t.Run("omit the route of requests matching none", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+
+		router := http.NewServeMux()
+		router.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		RequestLogger(logger, nil)(router).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/unknown", nil))
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, "/unknown", logOutput.URL.Path)
+		require.NotContains(t, buffer.String(), `"route"`)
+	})
+}
+
 func BenchmarkRequestLogger(b *testing.B) {
 	buffer := bytes.Buffer{}
 	logger, _ := zp.Init(zp.InitOptions{Level: "trace", Writer: &buffer})
@@ -279,7 +640,7 @@ func assertResponseLog(t testing.TB, expected logFields, actual *bytes.Buffer) {
 func getRequestWithHeaders(method, path string, body io.Reader) *http.Request {
 	request := httptest.NewRequest(method, path, body)
 	ip := removePort(request.RemoteAddr)
-	request.Header.Set(requestIDHeaderKey, requestID)
+	request.Header.Set(zpm.RequestIDHeaderKey, requestID)
 	request.Header.Set("User-Agent", userAgent)
 	request.Header.Set(forwardedForHeaderKey, ip)
 	request.Header.Set(forwardedHostHeaderKey, clientHost)
@@ -18,6 +18,8 @@ package std
 
 import (
 	"net/http"
+
+	zpm "github.com/danibix95/zeropino/middlewares"
 )
 
 // readableResponseWriter struct, add readable statusCode to ResponseWriter
@@ -25,6 +27,8 @@ type readableResponseWriter struct {
 	writer     http.ResponseWriter
 	statusCode int
 	length     int
+	// body keeps the beginning of the response body when it is captured
+	body *zpm.BodyBuffer
 }
 
 // WriteHeader func, set statusCode parameter
@@ -42,6 +46,9 @@ func (r *readableResponseWriter) Write(b []byte) (int, error) {
 	}
 
 	r.length += n
+	if r.body != nil {
+		_, _ = r.body.Write(b[:n])
+	}
 	return n, err
 }
 
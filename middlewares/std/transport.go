@@ -0,0 +1,136 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package std
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	zp "github.com/danibix95/zeropino"
+	zpm "github.com/danibix95/zeropino/middlewares"
+)
+
+// loggingTransport logs the requests sent to other services
+type loggingTransport struct {
+	next http.RoundTripper
+}
+
+// LoggingTransport wraps an http.RoundTripper, http.DefaultTransport when nil, so that
+// each outgoing request is logged with the logger stored in its context, as RequestLogger
+// does for incoming requests. The ID of the request being served, when stored in the context,
+// is forwarded in the X-Request-ID header, so that the called service logs it too, together
+// with its trace context in traceparent and tracestate headers. Each outgoing request has its
+// own span ID, sent as traceparent parent-id and logged as outgoingSpanId.
+func LoggingTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &loggingTransport{next: next}
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *loggingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	logger := Get(r.Context())
+
+	requestID := zp.RequestID(r.Context())
+	traceContext, traced := zpm.GetTraceContext(r.Context())
+	forwardID := requestID != "" && r.Header.Get(zpm.RequestIDHeaderKey) == ""
+	forwardTrace := traced && r.Header.Get(zpm.TraceParentHeaderKey) == ""
+
+	if forwardID || forwardTrace {
+		// a RoundTripper must not modify the request it receives
+		r = r.Clone(r.Context())
+	}
+	if forwardID {
+		r.Header.Set(zpm.RequestIDHeaderKey, requestID)
+	}
+	if forwardTrace {
+		// the outgoing request is a new span, child of the one of the request being served
+		outgoing := traceContext.Child()
+		r.Header.Set(zpm.TraceParentHeaderKey, outgoing.TraceParent())
+		if outgoing.State != "" {
+			r.Header.Set(zpm.TraceStateHeaderKey, outgoing.State)
+		}
+
+		outgoingLogger := logger.With().Str("outgoingSpanId", outgoing.SpanID).Logger()
+		logger = &outgoingLogger
+	}
+
+	logger.Trace().
+		Dict("http", zerolog.Dict().
+			Dict("request", requestDict(r)),
+		).
+		Dict("url", urlDict(r)).
+		Dict("host", hostDict(r)).
+		Msg("outgoing request")
+
+	response, err := t.next.RoundTrip(r)
+	responseTime := float64(time.Since(start).Nanoseconds()) / million
+
+	if err != nil {
+		logger.Error().
+			Object(zp.ErrorKey, zp.ErrorObject(err)).
+			Dict("http", zerolog.Dict().
+				Dict("request", requestDict(r)),
+			).
+			Dict("url", urlDict(r)).
+			Dict("host", hostDict(r)).
+			Float64("responseTime", responseTime).
+			Msg("outgoing request failed")
+		return nil, err
+	}
+
+	responseDict := zerolog.Dict().Int("statusCode", response.StatusCode)
+	if response.ContentLength >= 0 {
+		responseDict.Dict("body", zerolog.Dict().
+			Int64("bytes", response.ContentLength),
+		)
+	}
+
+	logger.Info().
+		Dict("http", zerolog.Dict().
+			Dict("request", requestDict(r)).
+			Dict("response", responseDict),
+		).
+		Dict("url", urlDict(r)).
+		Dict("host", hostDict(r)).
+		Float64("responseTime", responseTime).
+		Msg("outgoing request completed")
+
+	return response, nil
+}
+
+func requestDict(r *http.Request) *zerolog.Event {
+	return zerolog.Dict().
+		Str("method", r.Method).
+		Dict("userAgent", zerolog.Dict().
+			Str("original", r.UserAgent()),
+		)
+}
+
+func urlDict(r *http.Request) *zerolog.Event {
+	return zerolog.Dict().
+		Str("path", r.URL.RequestURI())
+}
+
+func hostDict(r *http.Request) *zerolog.Event {
+	return zerolog.Dict().
+		Str("hostname", r.URL.Hostname())
+}
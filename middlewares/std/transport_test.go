@@ -0,0 +1,157 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package std
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	zp "github.com/danibix95/zeropino"
+	zpm "github.com/danibix95/zeropino/middlewares"
+	"github.com/danibix95/zeropino/pino"
+)
+
+func TestLoggingTransport(t *testing.T) {
+	downstreamRequestIDs := make(chan string, 1)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstreamRequestIDs <- r.Header.Get(zpm.RequestIDHeaderKey)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("accepted"))
+	}))
+	defer downstream.Close()
+
+	t.Run("log outgoing requests forwarding the request ID", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Level: "trace", Writer: buffer})
+		client := &http.Client{Transport: LoggingTransport(nil)}
+
+		handler := RequestLogger(logger, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			request, err := http.NewRequestWithContext(r.Context(), http.MethodPost, downstream.URL+"/rings?bearer=frodo", nil)
+			require.NoError(t, err)
+			request.Header.Set("User-Agent", userAgent)
+
+			response, err := client.Do(request)
+			require.NoError(t, err)
+			defer response.Body.Close()
+			_, _ = io.Copy(io.Discard, response.Body)
+
+			require.Empty(t, request.Header.Get(zpm.RequestIDHeaderKey), "The original request is not modified")
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), getRequestWithHeaders(method, defaultRequestURL, nil))
+
+		require.Equal(t, requestID, <-downstreamRequestIDs)
+
+		entries := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		require.Equal(t, 4, len(entries), "Incoming and outgoing requests are both logged")
+
+		expected := logFields{
+			Level:     string(pino.Trace),
+			Msg:       "outgoing request",
+			RequestID: requestID,
+			Method:    http.MethodPost,
+			Original:  userAgent,
+			Path:      "/rings?bearer=frodo",
+			Hostname:  "127.0.0.1",
+		}
+		assertRequestLog(t, expected, bytes.NewBufferString(entries[1]))
+
+		expected.Level = string(pino.Info)
+		expected.Msg = "outgoing request completed"
+		expected.StatusCode = http.StatusAccepted
+		expected.Bytes = len("accepted")
+		assertResponseLog(t, expected, bytes.NewBufferString(entries[2]))
+	})
+
+	t.Run("keep the request ID already set", func(t *testing.T) {
+		logger, _ := zp.Init(zp.InitOptions{Writer: io.Discard})
+		ctx := zp.WithRequestID(WithLogger(context.Background(), logger), requestID)
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, downstream.URL, nil)
+		require.NoError(t, err)
+		request.Header.Set(zpm.RequestIDHeaderKey, "downstream-id")
+
+		response, err := LoggingTransport(http.DefaultTransport).RoundTrip(request)
+		require.NoError(t, err)
+		response.Body.Close()
+
+		require.Equal(t, "downstream-id", <-downstreamRequestIDs)
+	})
+
+	t.Run("forward the trace context", func(t *testing.T) {
+		traceParents := make(chan string, 1)
+		traced := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceParents <- r.Header.Get(zpm.TraceParentHeaderKey) + " " + r.Header.Get(zpm.TraceStateHeaderKey)
+		}))
+		defer traced.Close()
+
+		traceContext, _ := zpm.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		traceContext.State = "vendor=value"
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+		ctx := zpm.WithTraceContext(WithLogger(context.Background(), logger), traceContext)
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, traced.URL, nil)
+		require.NoError(t, err)
+
+		response, err := LoggingTransport(nil).RoundTrip(request)
+		require.NoError(t, err)
+		response.Body.Close()
+
+		traceHeaders := strings.Split(<-traceParents, " ")
+		require.Equal(t, "vendor=value", traceHeaders[1])
+		outgoing, ok := zpm.ParseTraceParent(traceHeaders[0])
+		require.True(t, ok)
+		require.Equal(t, traceContext.TraceID, outgoing.TraceID)
+		require.True(t, outgoing.Sampled)
+		require.NotEqual(t, traceContext.SpanID, outgoing.SpanID, "The outgoing request has its own span")
+
+		var logOutput zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &logOutput))
+		require.Equal(t, outgoing.SpanID, logOutput.OutgoingSpanID)
+		require.Empty(t, request.Header.Get(zpm.TraceParentHeaderKey), "The original request is not modified")
+	})
+
+	t.Run("log failed outgoing requests", func(t *testing.T) {
+		unreachable := httptest.NewServer(http.NotFoundHandler())
+		unreachable.Close()
+
+		buffer := &bytes.Buffer{}
+		logger, _ := zp.Init(zp.InitOptions{Writer: buffer})
+		ctx := WithLogger(context.Background(), logger)
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, unreachable.URL, nil)
+		require.NoError(t, err)
+
+		_, err = LoggingTransport(nil).RoundTrip(request)
+		require.Error(t, err)
+
+		var entry zpm.LogFormat
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &entry))
+		require.Equal(t, string(pino.Error), entry.Level)
+		require.Equal(t, "outgoing request failed", entry.Msg)
+		require.NotNil(t, entry.Err)
+		require.Contains(t, entry.Err.Message, "connection refused")
+	})
+}
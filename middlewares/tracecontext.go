@@ -0,0 +1,145 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// W3C Trace Context headers, see https://www.w3.org/TR/trace-context/
+const (
+	TraceParentHeaderKey = "traceparent"
+	TraceStateHeaderKey  = "tracestate"
+)
+
+const (
+	traceParentLength = 55
+	traceIDLength     = 32
+	spanIDLength      = 16
+	sampledFlag       = 0x01
+)
+
+// TraceContext is the W3C trace context of a request, which zeropino
+// middlewares write to the request logger as traceId, spanId and traceSampled fields
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+	// State is the vendor specific tracestate header, forwarded unchanged
+	State string
+}
+
+// ParseTraceParent parses a traceparent header, reporting whether it is valid.
+// Versions following 00 are accepted as long as they start with the fields of version 00.
+func ParseTraceParent(traceParent string) (TraceContext, bool) {
+	if len(traceParent) < traceParentLength {
+		return TraceContext{}, false
+	}
+
+	version := traceParent[0:2]
+	if !isLowerHex(version) || version == "ff" ||
+		(version == "00" && len(traceParent) != traceParentLength) ||
+		(len(traceParent) > traceParentLength && traceParent[traceParentLength] != '-') {
+		return TraceContext{}, false
+	}
+
+	parts := strings.SplitN(traceParent[:traceParentLength], "-", 4)
+	if len(parts) != 4 || len(parts[1]) != traceIDLength || len(parts[2]) != spanIDLength || len(parts[3]) != 2 {
+		return TraceContext{}, false
+	}
+
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) || isZero(traceID) || isZero(spanID) {
+		return TraceContext{}, false
+	}
+
+	flagsValue, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, SpanID: spanID, Sampled: flagsValue[0]&sampledFlag != 0}, true
+}
+
+// NewTraceContext generates a trace context with random trace and span IDs, not sampled
+func NewTraceContext() TraceContext {
+	return TraceContext{TraceID: randomHex(traceIDLength / 2), SpanID: randomHex(spanIDLength / 2)}
+}
+
+// Child returns the trace context of an operation started by the one of tc, such as
+// a request sent to another service, which shares its trace but has a new span ID
+func (tc TraceContext) Child() TraceContext {
+	tc.SpanID = randomHex(spanIDLength / 2)
+	return tc
+}
+
+// TraceParent returns the traceparent header of the trace context
+func (tc TraceContext) TraceParent() string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + flags
+}
+
+// RequestTraceContext returns the trace context carried by the request headers, read through get,
+// or a new one when it is missing or invalid, reporting whether it has been generated
+func RequestTraceContext(get func(key string) string) (TraceContext, bool) {
+	tc, ok := ParseTraceParent(get(TraceParentHeaderKey))
+	if !ok {
+		return NewTraceContext(), true
+	}
+
+	tc.State = get(TraceStateHeaderKey)
+	return tc, false
+}
+
+type traceContextKey struct{}
+
+// WithTraceContext returns a new context carrying the trace context of the request being served
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// GetTraceContext returns the trace context stored in the context, reporting whether there is one
+func GetTraceContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+func randomHex(size int) string {
+	buffer := make([]byte, size)
+	for {
+		// crypto/rand never fails on supported platforms
+		_, _ = rand.Read(buffer)
+		if !isZero(hex.EncodeToString(buffer)) {
+			return hex.EncodeToString(buffer)
+		}
+	}
+}
+
+func isLowerHex(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if (value[i] < '0' || value[i] > '9') && (value[i] < 'a' || value[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(value string) bool {
+	return strings.Trim(value, "0") == ""
+}
@@ -0,0 +1,84 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package middlewares
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	traceContext, ok := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	require.Equal(t, TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true}, traceContext)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceContext.TraceParent())
+
+	traceContext, ok = ParseTraceParent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-02-future")
+	require.True(t, ok, "Future versions are parsed as version 00")
+	require.False(t, traceContext.Sampled)
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",
+		"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01future",
+	}
+	for _, traceParent := range invalid {
+		_, ok := ParseTraceParent(traceParent)
+		require.False(t, ok, traceParent)
+	}
+}
+
+func TestRequestTraceContext(t *testing.T) {
+	headers := map[string]string{
+		TraceParentHeaderKey: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		TraceStateHeaderKey:  "vendor=value",
+	}
+	get := func(key string) string { return headers[key] }
+
+	traceContext, generated := RequestTraceContext(get)
+	require.False(t, generated)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceContext.TraceID)
+	require.Equal(t, "vendor=value", traceContext.State)
+
+	headers[TraceParentHeaderKey] = "invalid"
+	traceContext, generated = RequestTraceContext(get)
+	require.True(t, generated)
+	require.Empty(t, traceContext.State, "The trace state of an invalid trace parent is discarded")
+
+	parsed, ok := ParseTraceParent(traceContext.TraceParent())
+	require.True(t, ok, "Generated trace contexts are valid")
+	require.Equal(t, traceContext, parsed)
+	require.NotEqual(t, traceContext.TraceID, NewTraceContext().TraceID)
+}
+
+func TestGetTraceContext(t *testing.T) {
+	_, ok := GetTraceContext(context.Background())
+	require.False(t, ok)
+
+	traceContext := NewTraceContext()
+	stored, ok := GetTraceContext(WithTraceContext(context.Background(), traceContext))
+	require.True(t, ok)
+	require.Equal(t, traceContext, stored)
+}
@@ -26,12 +26,15 @@ type HTTP struct {
 type Request struct {
 	Method    string                 `json:"method,omitempty"`
 	UserAgent map[string]interface{} `json:"userAgent,omitempty"`
+	Body      map[string]interface{} `json:"body,omitempty"`
+	Headers   map[string]string      `json:"headers,omitempty"`
 }
 
 // Response contains the items of response info log.
 type Response struct {
 	StatusCode int                    `json:"statusCode,omitempty"`
 	Body       map[string]interface{} `json:"body,omitempty"`
+	Headers    map[string]string      `json:"headers,omitempty"`
 }
 
 // Host has the host information.
@@ -46,17 +49,58 @@ type URL struct {
 	Path string `json:"path,omitempty"`
 }
 
+// Error is the pino shape of a logged error
+type Error struct {
+	Type            string  `json:"type,omitempty"`
+	Message         string  `json:"message,omitempty"`
+	Stack           string  `json:"stack,omitempty"`
+	Cause           *Error  `json:"cause,omitempty"`
+	AggregateErrors []Error `json:"aggregateErrors,omitempty"`
+}
+
 // LogFormat represents the final log structure adopter by provided middlewares
 type LogFormat struct {
-	Level        string      `json:"level,omitempty"`
-	Pid          int         `json:"pid,omitempty"`
-	Hostname     string      `json:"hostname,omitempty"`
-	Time         int         `json:"time,omitempty"`
-	Msg          string      `json:"msg,omitempty"`
-	Stack        interface{} `json:"error,omitempty"`
-	RequestID    string      `json:"reqId,omitempty"`
-	HTTP         HTTP        `json:"http,omitempty"`
-	URL          URL         `json:"url,omitempty"`
-	Host         Host        `json:"host,omitempty"`
-	ResponseTime float64     `json:"responseTime,omitempty"`
+	Level     string      `json:"level,omitempty"`
+	Pid       int         `json:"pid,omitempty"`
+	Hostname  string      `json:"hostname,omitempty"`
+	Time      int         `json:"time,omitempty"`
+	Msg       string      `json:"msg,omitempty"`
+	Stack     interface{} `json:"error,omitempty"`
+	Err       *Error      `json:"err,omitempty"`
+	RequestID string      `json:"reqId,omitempty"`
+	TraceID   string      `json:"traceId,omitempty"`
+	SpanID    string      `json:"spanId,omitempty"`
+	// OutgoingSpanID is the span ID sent as parent-id to the called service
+	OutgoingSpanID string  `json:"outgoingSpanId,omitempty"`
+	TraceSampled   bool    `json:"traceSampled,omitempty"`
+	HTTP           HTTP    `json:"http,omitempty"`
+	URL            URL     `json:"url,omitempty"`
+	Route          string  `json:"route,omitempty"`
+	Host           Host    `json:"host,omitempty"`
+	ResponseTime   float64 `json:"responseTime,omitempty"`
+}
+
+// Options configures zeropino request logger middlewares
+type Options struct {
+	// ExcludedPrefixes are the prefixes of the request paths that are not logged
+	ExcludedPrefixes []string
+	// DisableTraceContext stops reading and generating W3C trace context headers
+	DisableTraceContext bool
+	// TraceIDAsRequestID uses the trace ID as request ID when no valid request ID header is found
+	TraceIDAsRequestID bool
+	// RequestIDHeaders are the headers carrying the request ID, checked in order, X-Request-ID by default
+	RequestIDHeaders []string
+	// RequestIDGenerator generates the request ID when no valid one is found, UUIDv4 by default,
+	// which is also used when the configured one fails
+	RequestIDGenerator RequestIDGenerator
+	// MaxRequestIDLength is the length above which inbound request IDs are discarded,
+	// DefaultMaxRequestIDLength by default. Request IDs containing characters that are
+	// not printable ASCII are always discarded.
+	MaxRequestIDLength int
+	// DisableRequestIDResponseHeader stops setting the request ID on the first of RequestIDHeaders of the response
+	DisableRequestIDResponseHeader bool
+	// BodyCapture configures the logging of request and response bodies, disabled by default
+	BodyCapture BodyCapture
+	// Headers configures the logging of request and response headers, disabled by default
+	Headers HeaderLogging
 }
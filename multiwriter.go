@@ -0,0 +1,99 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+// Destination is a writer receiving only the logs whose level is at least its minimum level
+type Destination struct {
+	Writer io.Writer
+	// Level is the minimum level of the logs written to Writer, using the same names
+	// accepted by InitOptions.Level. When empty, all the logs produced by the logger are written.
+	Level string
+}
+
+type levelDestination struct {
+	writer zerolog.LevelWriter
+	level  zerolog.Level
+}
+
+// multiLevelWriter writes each log to all the destinations accepting its level,
+// as pino multistream does
+type multiLevelWriter struct {
+	destinations []levelDestination
+}
+
+func newMultiLevelWriter(destinations []Destination) (*multiLevelWriter, error) {
+	mw := &multiLevelWriter{destinations: make([]levelDestination, 0, len(destinations))}
+
+	var errs []error
+	for i, destination := range destinations {
+		if destination.Writer == nil {
+			errs = append(errs, fmt.Errorf("destination %d: writer is required", i))
+			continue
+		}
+
+		level := zerolog.TraceLevel
+		if destination.Level != "" {
+			var err error
+			if level, err = pino.ParseLevel(destination.Level); err != nil {
+				errs = append(errs, fmt.Errorf("destination %d: %w", i, err))
+				continue
+			}
+		}
+
+		mw.destinations = append(mw.destinations, levelDestination{writer: levelWriter(destination.Writer), level: level})
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return mw, nil
+}
+
+// Write implements io.Writer interface
+func (mw *multiLevelWriter) Write(p []byte) (int, error) {
+	return mw.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter interface. A failing destination
+// does not prevent the log from being written to the other ones.
+func (mw *multiLevelWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	var errs []error
+	for _, destination := range mw.destinations {
+		if !pino.Enabled(level, destination.level) {
+			continue
+		}
+
+		if _, err := destination.writer.WriteLevel(level, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return 0, errors.Join(errs...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,102 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestDestinations(t *testing.T) {
+	t.Run("Write logs to destinations according to their level", func(t *testing.T) {
+		collector := &bytes.Buffer{}
+		triage := &bytes.Buffer{}
+		logger, err := Init(InitOptions{
+			Level: "debug",
+			Destinations: []Destination{
+				{Writer: collector, Level: "info"},
+				{Writer: triage, Level: "error"},
+			},
+		})
+		verifyInit(t, logger, err, zerolog.DebugLevel)
+
+		logger.Debug().Msg(message)
+		logger.Info().Msg(message)
+		logger.Error().Msg(message)
+
+		collectorLines := strings.Split(strings.TrimSpace(collector.String()), "\n")
+		require.Len(t, collectorLines, 2)
+
+		result := miaLog{}
+		require.Nil(t, json.Unmarshal(triage.Bytes(), &result), "No error raised")
+		verifyLog(t, &result, message, string(pino.Error), unixTimestampMsLen)
+	})
+
+	t.Run("Writer is added as a destination without level", func(t *testing.T) {
+		all := &bytes.Buffer{}
+		triage := &bytes.Buffer{}
+		logger, err := Init(InitOptions{
+			Level:        "trace",
+			Writer:       all,
+			Destinations: []Destination{{Writer: triage, Level: "warn"}},
+		})
+		verifyInit(t, logger, err, zerolog.TraceLevel)
+
+		logger.Trace().Msg(message)
+		logger.Warn().Msg(message)
+
+		require.Equal(t, 2, strings.Count(all.String(), "\n"))
+		require.Equal(t, 1, strings.Count(triage.String(), "\n"))
+	})
+
+	t.Run("Invalid destinations are reported", func(t *testing.T) {
+		logger, err := Init(InitOptions{
+			Destinations: []Destination{
+				{Writer: &bytes.Buffer{}, Level: "custom"},
+				{Level: "info"},
+			},
+		})
+
+		var emptyPointer *zerolog.Logger
+		require.Equal(t, emptyPointer, logger)
+		require.EqualError(t, err, "destination 0: level custom is not recognized\ndestination 1: writer is required")
+	})
+
+	t.Run("A failing destination does not stop the others", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer, err := newMultiLevelWriter([]Destination{{Writer: failingWriter{}}, {Writer: out}})
+		require.NoError(t, err)
+
+		_, err = writer.WriteLevel(zerolog.InfoLevel, []byte(logLine))
+		require.EqualError(t, err, "broken pipe")
+		require.Equal(t, logLine, out.String())
+	})
+}
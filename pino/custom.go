@@ -0,0 +1,177 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package pino
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// firstCustomLevel is the first zerolog level assigned to custom levels,
+// following the ones already defined by zerolog
+const firstCustomLevel = zerolog.Disabled + 1
+
+type customLevel struct {
+	name  string
+	value int
+}
+
+// registry collects the custom levels, which are expected to be registered
+// at startup and read by every logger afterwards
+var registry = struct {
+	sync.RWMutex
+	byName  map[string]zerolog.Level
+	byLevel map[zerolog.Level]customLevel
+}{
+	byName:  map[string]zerolog.Level{},
+	byLevel: map[zerolog.Level]customLevel{},
+}
+
+// RegisterLevel Register a custom level with the given name and pino value, e.g. audit with 35,
+// returning the zerolog level that identifies it. Registering again the same level returns
+// the same zerolog level, while names and values already in use are rejected.
+func RegisterLevel(name string, value int) (zerolog.Level, error) {
+	name = strings.ToLower(name)
+	if name == "" {
+		return zerolog.NoLevel, errors.New("custom level name is required")
+	}
+	if value <= 0 {
+		return zerolog.NoLevel, fmt.Errorf("custom level %s: value %d must be positive", name, value)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if level, ok := registry.byName[name]; ok {
+		if registry.byLevel[level].value != value {
+			return zerolog.NoLevel, fmt.Errorf("custom level %s is already registered with value %d", name, registry.byLevel[level].value)
+		}
+		return level, nil
+	}
+
+	if name == "silent" {
+		return zerolog.NoLevel, fmt.Errorf("custom level %s: name is reserved", name)
+	}
+	for _, level := range levels {
+		if LevelName(level) == name {
+			return zerolog.NoLevel, fmt.Errorf("custom level %s: name is already used", name)
+		}
+		if rank(level) == value {
+			return zerolog.NoLevel, fmt.Errorf("custom level %s: value %d is already used by %s level", name, value, LevelName(level))
+		}
+	}
+	for _, custom := range registry.byLevel {
+		if custom.value == value {
+			return zerolog.NoLevel, fmt.Errorf("custom level %s: value %d is already used by %s level", name, value, custom.name)
+		}
+	}
+
+	if len(registry.byLevel) > math.MaxInt8-int(firstCustomLevel) {
+		return zerolog.NoLevel, fmt.Errorf("custom level %s: too many custom levels", name)
+	}
+
+	level := firstCustomLevel + zerolog.Level(len(registry.byLevel))
+	registry.byName[name] = level
+	registry.byLevel[level] = customLevel{name: name, value: value}
+
+	return level, nil
+}
+
+// HasCustomLevels Report whether any custom level has been registered
+func HasCustomLevels() bool {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return len(registry.byLevel) > 0
+}
+
+// IsCustomLevel Report whether the level identifies a custom level, rather than a zerolog one
+func IsCustomLevel(level zerolog.Level) bool {
+	return level >= firstCustomLevel
+}
+
+func lookupCustomLevel(level zerolog.Level) (customLevel, bool) {
+	if level < firstCustomLevel {
+		return customLevel{}, false
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+
+	custom, ok := registry.byLevel[level]
+	return custom, ok
+}
+
+func lookupCustomName(name string) (zerolog.Level, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	level, ok := registry.byName[name]
+	return level, ok
+}
+
+// rank returns the position of the level in pino ordering, which is its pino value.
+// Events without level are ranked below disabled level only, so that they are
+// always logged unless the logger is disabled.
+func rank(level zerolog.Level) int {
+	switch level {
+	case zerolog.Disabled:
+		return math.MaxInt
+	case zerolog.NoLevel:
+		return math.MaxInt - 1
+	}
+
+	// pino levels follow zerolog ones, from trace with 10 to panic with 70
+	if level >= zerolog.TraceLevel && level <= zerolog.PanicLevel {
+		return int(level-zerolog.TraceLevel+1) * 10
+	}
+	if custom, ok := lookupCustomLevel(level); ok {
+		return custom.value
+	}
+	return 0
+}
+
+// Enabled Report whether events of the given level are logged by a logger with the minimum level,
+// comparing levels through their pino values, so that custom levels are ordered as expected
+func Enabled(level, minimum zerolog.Level) bool {
+	return rank(level) >= rank(minimum)
+}
+
+// orderedLevels returns the pino levels together with the custom ones, from the lowest to the highest
+func orderedLevels() []zerolog.Level {
+	if !HasCustomLevels() {
+		return levels
+	}
+
+	registry.RLock()
+	all := append([]zerolog.Level(nil), levels...)
+	for level := range registry.byLevel {
+		all = append(all, level)
+	}
+	registry.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return rank(all[i]) < rank(all[j])
+	})
+	return all
+}
@@ -0,0 +1,146 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package pino_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danibix95/zeropino"
+	"github.com/danibix95/zeropino/pino"
+)
+
+func TestCustomLevels(t *testing.T) {
+	t.Cleanup(pino.UnregisterLevels)
+
+	earlierOut := &bytes.Buffer{}
+	earlier, err := zeropino.Init(zeropino.InitOptions{Level: "warn", Writer: earlierOut})
+	require.NoError(t, err)
+
+	audit, err := zeropino.RegisterLevel("audit", 35)
+	require.NoError(t, err)
+	notice, err := zeropino.RegisterLevel("Notice", 45)
+	require.NoError(t, err)
+
+	t.Run("Register a custom level", func(t *testing.T) {
+		again, err := zeropino.RegisterLevel("audit", 35)
+		require.NoError(t, err)
+		require.Equal(t, audit, again, "Registering the same level returns the same zerolog level")
+
+		_, err = zeropino.RegisterLevel("audit", 36)
+		require.EqualError(t, err, "custom level audit is already registered with value 35")
+		_, err = zeropino.RegisterLevel("info", 33)
+		require.EqualError(t, err, "custom level info: name is already used")
+		_, err = zeropino.RegisterLevel("verbose", 30)
+		require.EqualError(t, err, "custom level verbose: value 30 is already used by info level")
+		_, err = zeropino.RegisterLevel("compliance", 45)
+		require.EqualError(t, err, "custom level compliance: value 45 is already used by notice level")
+		_, err = zeropino.RegisterLevel("negative", 0)
+		require.Error(t, err)
+	})
+
+	t.Run("Parse and convert a custom level", func(t *testing.T) {
+		level, err := pino.ParseLevel("AUDIT")
+		require.NoError(t, err)
+		require.Equal(t, audit, level)
+
+		require.Equal(t, "35", pino.ConvertLevel(audit))
+		require.Equal(t, "notice", pino.LevelName(notice))
+		require.Equal(t, notice, pino.StepLevel(zerolog.WarnLevel, 1), "Custom levels are steps too")
+		require.Equal(t, audit, pino.StepLevel(zerolog.InfoLevel, 1))
+	})
+
+	t.Run("Filter events by custom levels", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := zeropino.Init(zeropino.InitOptions{Level: "audit", Writer: out, LevelFormat: zeropino.LevelFormatNumberAndLabel})
+		require.NoError(t, err)
+
+		logger.Info().Msg("dropped")
+		logger.WithLevel(audit).Msg("audit")
+		logger.WithLevel(notice).Msg("notice")
+		logger.Warn().Msg("warn")
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 3)
+		require.Contains(t, lines[0], `"level":35,"label":"audit"`)
+		require.Contains(t, lines[1], `"level":45,"label":"notice"`)
+		require.Contains(t, lines[2], `"level":40,"label":"warn"`)
+
+		out.Reset()
+		infoLogger, err := zeropino.Init(zeropino.InitOptions{Level: "info", Writer: out})
+		require.NoError(t, err)
+
+		infoLogger.Debug().Msg("dropped")
+		infoLogger.WithLevel(audit).Msg("audit")
+		require.Contains(t, out.String(), `"level":"35"`)
+		require.NotContains(t, out.String(), "dropped")
+	})
+
+	t.Run("Filter custom levels registered after creating the logger", func(t *testing.T) {
+		earlier.WithLevel(audit).Msg("dropped")
+		earlier.WithLevel(notice).Msg("kept")
+		require.NotContains(t, earlierOut.String(), "dropped")
+		require.Contains(t, earlierOut.String(), `"level":"45"`)
+		require.Equal(t, zerolog.WarnLevel, earlier.GetLevel(), "Zerolog level keeps filtering the other levels")
+
+		silent, err := zeropino.Init(zeropino.InitOptions{Level: "silent", Writer: earlierOut})
+		require.NoError(t, err)
+		earlierOut.Reset()
+		silent.WithLevel(notice).Msg("dropped")
+		require.Zero(t, earlierOut.Len())
+	})
+
+	t.Run("Filter custom levels by the global level and without sampler", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := zeropino.Init(zeropino.InitOptions{Level: "warn", Writer: out})
+		require.NoError(t, err)
+
+		sampled := logger.Sample(&zerolog.BasicSampler{N: 1})
+		sampled.WithLevel(audit).Msg("dropped")
+		require.Zero(t, out.Len(), "Replacing the sampler keeps filtering custom levels")
+
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+		defer zerolog.SetGlobalLevel(zerolog.TraceLevel)
+		logger.WithLevel(notice).Msg("dropped")
+		require.Zero(t, out.Len(), "Custom levels are below the disabled global level")
+
+		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+		logger.WithLevel(notice).Msg("dropped")
+		require.Zero(t, out.Len(), "Custom levels are compared with the global level by their value")
+
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+		logger.WithLevel(notice).Msg("kept")
+		require.Contains(t, out.String(), "kept")
+	})
+
+	t.Run("Change the atomic level to a custom level", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		level := zeropino.NewAtomicLevel()
+		logger, err := zeropino.Init(zeropino.InitOptions{Writer: out, AtomicLevel: level})
+		require.NoError(t, err)
+
+		level.SetLevel(notice)
+		logger.WithLevel(audit).Msg("dropped")
+		logger.Error().Msg("kept")
+		require.NotContains(t, out.String(), "dropped")
+		require.Contains(t, out.String(), "kept")
+	})
+}
@@ -0,0 +1,203 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+// Package pino describes pino log levels and how they map onto zerolog ones,
+// so that applications can validate level names read from their configuration
+// and interpret the levels of the logs produced by zeropino
+package pino
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Level is a string representation of levels adopted PinoJS logger
+type Level string
+
+// Represents all the Pino log levels accepted
+// Note: the Panic level has been added to cover this log level not available in Javascript
+const (
+	Trace Level = "10"
+	Debug Level = "20"
+	Info  Level = "30"
+	Warn  Level = "40"
+	Error Level = "50"
+	Fatal Level = "60"
+	Panic Level = "70"
+)
+
+// ConvertLevel Convert a zerolog log level into the corresponding pino one
+func ConvertLevel(level zerolog.Level) string {
+	var pinoLevel Level
+	switch level {
+	case zerolog.TraceLevel:
+		pinoLevel = Trace
+	case zerolog.DebugLevel:
+		pinoLevel = Debug
+	case zerolog.InfoLevel:
+		pinoLevel = Info
+	case zerolog.WarnLevel:
+		pinoLevel = Warn
+	case zerolog.ErrorLevel:
+		pinoLevel = Error
+	case zerolog.FatalLevel:
+		pinoLevel = Fatal
+	case zerolog.PanicLevel:
+		pinoLevel = Panic
+	case zerolog.Disabled:
+		fallthrough
+	case zerolog.NoLevel:
+		return ""
+	default:
+		if custom, ok := lookupCustomLevel(level); ok {
+			return strconv.Itoa(custom.value)
+		}
+		return ""
+	}
+
+	return string(pinoLevel)
+}
+
+// ParseLevel Parse a string name of the log level, including custom ones, or its pino value,
+// e.g. "info", "INFO" or "30", and return the corresponding zerolog level.
+// An empty string is parsed as info level.
+func ParseLevel(level string) (zerolog.Level, error) {
+	if len(level) > 0 {
+		switch name := strings.ToLower(level); name {
+		case "trace":
+			return zerolog.TraceLevel, nil
+		case "debug":
+			return zerolog.DebugLevel, nil
+		case "info":
+			return zerolog.InfoLevel, nil
+		case "warn":
+			return zerolog.WarnLevel, nil
+		case "error":
+			return zerolog.ErrorLevel, nil
+		case "fatal":
+			return zerolog.FatalLevel, nil
+		case "panic":
+			return zerolog.PanicLevel, nil
+		case "silent":
+			return zerolog.Disabled, nil
+		default:
+			if custom, ok := lookupCustomName(name); ok {
+				return custom, nil
+			}
+			if value, err := strconv.Atoi(name); err == nil {
+				if parsed, err := LevelFromValue(value); err == nil {
+					return parsed, nil
+				}
+			}
+			return zerolog.NoLevel, fmt.Errorf("level %s is not recognized", level)
+		}
+	}
+
+	return zerolog.InfoLevel, nil
+}
+
+// LevelFromValue Convert a pino level value, including the ones of custom levels,
+// into the corresponding zerolog level, e.g. 30 into zerolog.InfoLevel
+func LevelFromValue(value int) (zerolog.Level, error) {
+	for _, level := range orderedLevels() {
+		if rank(level) == value {
+			return level, nil
+		}
+	}
+	return zerolog.NoLevel, fmt.Errorf("level value %d is not recognized", value)
+}
+
+// LevelValue Convert a zerolog log level into its pino value, reporting
+// whether the level has one, which is not the case of disabled and no level
+func LevelValue(level zerolog.Level) (int, bool) {
+	if value := rank(level); value > 0 && level != zerolog.Disabled && level != zerolog.NoLevel {
+		return value, true
+	}
+	return 0, false
+}
+
+// LevelNames Return the names accepted by ParseLevel, including custom ones,
+// from the lowest to the highest level and followed by silent, e.g. to list them in a flag help text
+func LevelNames() []string {
+	levels := orderedLevels()
+
+	names := make([]string, 0, len(levels)+1)
+	for _, level := range levels {
+		names = append(names, LevelName(level))
+	}
+	return append(names, LevelName(zerolog.Disabled))
+}
+
+// LevelName Convert a zerolog log level into the corresponding name accepted by ParseLevel
+func LevelName(level zerolog.Level) string {
+	switch level {
+	case zerolog.Disabled:
+		return "silent"
+	case zerolog.NoLevel:
+		return ""
+	default:
+		if custom, ok := lookupCustomLevel(level); ok {
+			return custom.name
+		}
+		return level.String()
+	}
+}
+
+// levels lists the zerolog levels matching pino ones, from the lowest to the highest
+var levels = []zerolog.Level{
+	zerolog.TraceLevel,
+	zerolog.DebugLevel,
+	zerolog.InfoLevel,
+	zerolog.WarnLevel,
+	zerolog.ErrorLevel,
+	zerolog.FatalLevel,
+	zerolog.PanicLevel,
+}
+
+// StepLevel Move the given zerolog level of the number of steps along pino levels,
+// including custom ones, where negative steps lower the level. The result never goes beyond trace and panic levels,
+// while a disabled level can only be lowered, starting from panic level.
+func StepLevel(level zerolog.Level, steps int) zerolog.Level {
+	levels := orderedLevels()
+
+	index := -1
+	for i, l := range levels {
+		if l == level {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		if level != zerolog.Disabled || steps >= 0 {
+			return level
+		}
+		index = len(levels)
+	}
+
+	index += steps
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(levels) {
+		index = len(levels) - 1
+	}
+
+	return levels[index]
+}
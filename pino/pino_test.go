@@ -0,0 +1,90 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package pino
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected zerolog.Level
+	}{
+		{input: "", expected: zerolog.InfoLevel},
+		{input: "debug", expected: zerolog.DebugLevel},
+		{input: "WARN", expected: zerolog.WarnLevel},
+		{input: "silent", expected: zerolog.Disabled},
+		{input: "10", expected: zerolog.TraceLevel},
+		{input: "70", expected: zerolog.PanicLevel},
+	}
+
+	for _, testCase := range testCases {
+		level, err := ParseLevel(testCase.input)
+		require.NoError(t, err, testCase.input)
+		require.Equal(t, testCase.expected, level, testCase.input)
+	}
+
+	for _, input := range []string{"verbose", "35", "-10"} {
+		_, err := ParseLevel(input)
+		require.EqualError(t, err, "level "+input+" is not recognized")
+	}
+}
+
+func TestLevelValues(t *testing.T) {
+	level, err := LevelFromValue(50)
+	require.NoError(t, err)
+	require.Equal(t, zerolog.ErrorLevel, level)
+
+	_, err = LevelFromValue(55)
+	require.EqualError(t, err, "level value 55 is not recognized")
+
+	value, ok := LevelValue(zerolog.FatalLevel)
+	require.True(t, ok)
+	require.Equal(t, 60, value)
+
+	_, ok = LevelValue(zerolog.Disabled)
+	require.False(t, ok)
+	_, ok = LevelValue(zerolog.NoLevel)
+	require.False(t, ok)
+
+	require.Equal(t, []string{"trace", "debug", "info", "warn", "error", "fatal", "panic", "silent"}, LevelNames())
+}
+
+func TestCustomLevelValues(t *testing.T) {
+	t.Cleanup(UnregisterLevels)
+
+	audit, err := RegisterLevel("audit", 35)
+	require.NoError(t, err)
+
+	level, err := ParseLevel("35")
+	require.NoError(t, err)
+	require.Equal(t, audit, level)
+
+	level, err = LevelFromValue(35)
+	require.NoError(t, err)
+	require.Equal(t, audit, level)
+
+	value, ok := LevelValue(audit)
+	require.True(t, ok)
+	require.Equal(t, 35, value)
+
+	require.Equal(t, []string{"trace", "debug", "info", "audit", "warn", "error", "fatal", "panic", "silent"}, LevelNames())
+}
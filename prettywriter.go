@@ -0,0 +1,403 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+const (
+	defaultPrettyTimeLayout = "15:04:05.000"
+
+	colorReset   = "\x1b[0m"
+	colorBold    = "\x1b[1m"
+	colorDim     = "\x1b[2m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+// accessKeys are the flattened fields written by zeropino middlewares that compose the access log line
+var accessKeys = map[string]bool{
+	"http.request.method":             true,
+	"http.response.statusCode":        true,
+	"http.response.body.bytes":        true,
+	"url.path":                        true,
+	"responseTime":                    true,
+	"http.request.userAgent.original": true,
+}
+
+// flattenedKeys are the keys of the objects whose fields are flattened into dotted keys
+var flattenedKeys = map[string]bool{"http": true, "url": true, "host": true}
+
+// PrettyOptions are the possible options that can be used to create a PrettyWriter
+type PrettyOptions struct {
+	// NoColor disables ANSI colors, e.g. when the output is not a terminal
+	NoColor bool
+	// TimeLayout is the layout times are written with, "15:04:05.000" by default
+	TimeLayout string
+	// Location is the time zone times are written in, time.Local by default
+	Location *time.Location
+	// TimeKey is the key of the time field, "time" by default
+	TimeKey string
+	// PidKey is the key of the process id field, "pid" by default
+	PidKey string
+	// HostnameKey is the key of the hostname field, "hostname" by default
+	HostnameKey string
+	// MessageKey is the key of the message field, "msg" by default
+	MessageKey string
+	// LabelKey is the key of the level name written by LevelFormatNumberAndLabel, "label" by default
+	LabelKey string
+}
+
+// PrettyWriter is an io.Writer that renders zeropino and pino JSON logs as colorized,
+// human-readable lines, as pino-pretty does, to be used as InitOptions.Writer in development.
+// Lines that are not JSON objects are written unchanged.
+type PrettyWriter struct {
+	out     io.Writer
+	options PrettyOptions
+}
+
+// prettyField is a field of a log, kept in the order it has been written
+type prettyField struct {
+	key   string
+	value json.RawMessage
+}
+
+// NewPrettyWriter creates a PrettyWriter writing to w
+func NewPrettyWriter(w io.Writer, options PrettyOptions) *PrettyWriter {
+	if options.TimeLayout == "" {
+		options.TimeLayout = defaultPrettyTimeLayout
+	}
+	if options.Location == nil {
+		options.Location = time.Local
+	}
+	if options.TimeKey == "" {
+		options.TimeKey = defaultTimeKey
+	}
+	if options.PidKey == "" {
+		options.PidKey = defaultPidKey
+	}
+	if options.HostnameKey == "" {
+		options.HostnameKey = defaultHostnameKey
+	}
+	if options.MessageKey == "" {
+		options.MessageKey = defaultMessageKey
+	}
+	if options.LabelKey == "" {
+		options.LabelKey = defaultLabelKey
+	}
+
+	return &PrettyWriter{out: w, options: options}
+}
+
+// Write implements io.Writer interface, rendering each line of p
+func (pw *PrettyWriter) Write(p []byte) (int, error) {
+	var buffer bytes.Buffer
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) > 0 {
+			pw.render(&buffer, line)
+		}
+	}
+
+	if _, err := pw.out.Write(buffer.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// render writes to buffer the human-readable version of the log line,
+// or the line unchanged when it is not a JSON object
+func (pw *PrettyWriter) render(buffer *bytes.Buffer, line []byte) {
+	fields, err := parseFields(line)
+	if err != nil {
+		buffer.Write(line)
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			buffer.WriteByte('\n')
+		}
+		return
+	}
+
+	values := map[string]json.RawMessage{}
+	for _, field := range fields {
+		values[field.key] = field.value
+	}
+
+	if value, ok := values[pw.options.TimeKey]; ok {
+		buffer.WriteString(pw.colorize(colorDim, "["+pw.formatTime(value)+"]"))
+		buffer.WriteByte(' ')
+	}
+
+	name, color := prettyLevel(values[defaultLevelKey])
+	buffer.WriteString(pw.colorize(color, fmt.Sprintf("%-5s", name)))
+
+	if pid, ok := values[pw.options.PidKey]; ok {
+		buffer.WriteString(" (" + rawString(pid))
+		if hostname, ok := values[pw.options.HostnameKey]; ok {
+			buffer.WriteString(" on " + rawString(hostname))
+		}
+		buffer.WriteByte(')')
+	}
+
+	if reqID, ok := values["reqId"]; ok {
+		buffer.WriteString(" " + pw.colorize(colorMagenta, "["+rawString(reqID)+"]"))
+	}
+
+	buffer.WriteByte(':')
+	if message, ok := values[pw.options.MessageKey]; ok {
+		buffer.WriteString(" " + pw.colorize(colorCyan, rawString(message)))
+	}
+
+	flattened := flattenFields(fields)
+	pw.writeAccess(buffer, flattened)
+
+	var stack string
+	for _, field := range flattened {
+		switch {
+		case field.key == defaultLevelKey || field.key == pw.options.LabelKey || field.key == pw.options.TimeKey ||
+			field.key == pw.options.PidKey || field.key == pw.options.HostnameKey || field.key == "reqId" ||
+			field.key == pw.options.MessageKey || accessKeys[field.key]:
+			continue
+		case field.key == ErrorKey:
+			stack = pw.writeError(buffer, field.value)
+			continue
+		}
+
+		if value := rawString(field.value); value != "" {
+			buffer.WriteString(" " + pw.colorize(colorGray, field.key+"=") + value)
+		}
+	}
+	buffer.WriteByte('\n')
+
+	if stack != "" {
+		for _, frame := range strings.Split(stack, "\n") {
+			buffer.WriteString("    " + pw.colorize(colorGray, frame) + "\n")
+		}
+	}
+}
+
+// writeAccess writes the request fields logged by zeropino middlewares as an access log line,
+// e.g. GET /welcome 200 1.234ms 42B
+func (pw *PrettyWriter) writeAccess(buffer *bytes.Buffer, fields []prettyField) {
+	values := map[string]string{}
+	for _, field := range fields {
+		if accessKeys[field.key] {
+			values[field.key] = rawString(field.value)
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	if method := values["http.request.method"]; method != "" {
+		buffer.WriteString(" " + pw.colorize(colorBold, method))
+	}
+	if path := values["url.path"]; path != "" {
+		buffer.WriteString(" " + path)
+	}
+	if statusCode := values["http.response.statusCode"]; statusCode != "" {
+		buffer.WriteString(" " + pw.colorize(statusColor(statusCode), statusCode))
+	}
+	if responseTime := values["responseTime"]; responseTime != "" {
+		buffer.WriteString(" " + responseTime + "ms")
+	}
+	if size := values["http.response.body.bytes"]; size != "" {
+		buffer.WriteString(" " + size + "B")
+	}
+	if userAgent := values["http.request.userAgent.original"]; userAgent != "" {
+		buffer.WriteString(" " + pw.colorize(colorGray, strconv.Quote(userAgent)))
+	}
+}
+
+// writeError writes the type and the message of an error serialized as pino does, returning its stack
+func (pw *PrettyWriter) writeError(buffer *bytes.Buffer, value json.RawMessage) string {
+	var serialized struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+		Stack   string `json:"stack"`
+	}
+	if err := json.Unmarshal(value, &serialized); err != nil || serialized.Message == "" {
+		buffer.WriteString(" " + pw.colorize(colorGray, ErrorKey+"=") + rawString(value))
+		return ""
+	}
+
+	buffer.WriteString(" " + pw.colorize(colorGray, ErrorKey+"=") + pw.colorize(colorRed, strconv.Quote(serialized.Message)))
+	if serialized.Type != "" {
+		buffer.WriteString(pw.colorize(colorGray, " ("+serialized.Type+")"))
+	}
+	return serialized.Stack
+}
+
+func (pw *PrettyWriter) formatTime(value json.RawMessage) string {
+	var text string
+	if json.Unmarshal(value, &text) == nil {
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return text
+		}
+		return t.In(pw.options.Location).Format(pw.options.TimeLayout)
+	}
+
+	epoch, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return string(value)
+	}
+	return EpochTime(epoch).In(pw.options.Location).Format(pw.options.TimeLayout)
+}
+
+func (pw *PrettyWriter) colorize(color, text string) string {
+	if pw.options.NoColor || color == "" {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// prettyLevel returns the uppercase name of the level and its color, accepting
+// pino values, either as numbers or strings, and level names
+func prettyLevel(value json.RawMessage) (string, string) {
+	if value == nil {
+		return "", ""
+	}
+
+	var level zerolog.Level
+	var err error
+	if number, parseErr := strconv.Atoi(string(value)); parseErr == nil {
+		level, err = pino.LevelFromValue(number)
+	} else {
+		level, err = pino.ParseLevel(rawString(value))
+	}
+	if err != nil {
+		return strings.ToUpper(rawString(value)), ""
+	}
+
+	name := strings.ToUpper(pino.LevelName(level))
+	switch level {
+	case zerolog.TraceLevel:
+		return name, colorGray
+	case zerolog.DebugLevel:
+		return name, colorBlue
+	case zerolog.InfoLevel:
+		return name, colorGreen
+	case zerolog.WarnLevel:
+		return name, colorYellow
+	case zerolog.ErrorLevel:
+		return name, colorRed
+	case zerolog.FatalLevel, zerolog.PanicLevel:
+		return name, colorBold + colorRed
+	default:
+		return name, colorCyan
+	}
+}
+
+func statusColor(statusCode string) string {
+	switch {
+	case strings.HasPrefix(statusCode, "5"):
+		return colorRed
+	case strings.HasPrefix(statusCode, "4"):
+		return colorYellow
+	case strings.HasPrefix(statusCode, "3"):
+		return colorCyan
+	default:
+		return colorGreen
+	}
+}
+
+// rawString returns JSON strings without quotes and any other value as it has been encoded
+func rawString(value json.RawMessage) string {
+	var text string
+	if json.Unmarshal(value, &text) == nil {
+		return text
+	}
+	return string(value)
+}
+
+// parseFields decodes the fields of a JSON object, preserving their order
+func parseFields(line []byte) ([]prettyField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errMalformedJSON
+	}
+
+	var fields []prettyField
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, errMalformedJSON
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, prettyField{key: key, value: value})
+	}
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('}') {
+		return nil, errMalformedJSON
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errMalformedJSON
+	}
+	return fields, nil
+}
+
+// flattenFields replaces the http, url and host objects with their fields,
+// whose keys are joined with dots, e.g. http.request.method
+func flattenFields(fields []prettyField) []prettyField {
+	flattened := make([]prettyField, 0, len(fields))
+	for _, field := range fields {
+		if !flattenedKeys[field.key] {
+			flattened = append(flattened, field)
+			continue
+		}
+		flattened = appendFlattened(flattened, field.key, field.value)
+	}
+	return flattened
+}
+
+func appendFlattened(dst []prettyField, prefix string, value json.RawMessage) []prettyField {
+	nested, err := parseFields(value)
+	if err != nil {
+		return append(dst, prettyField{key: prefix, value: value})
+	}
+
+	for _, field := range nested {
+		dst = appendFlattened(dst, prefix+"."+field.key, field.value)
+	}
+	return dst
+}
@@ -0,0 +1,115 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrettyWriter(t *testing.T) {
+	t.Run("Render a log written by a zeropino Logger", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: NewPrettyWriter(out, PrettyOptions{NoColor: true})})
+		require.NoError(t, err)
+
+		logger.Warn().Str("ring", "one").Int("bearers", 9).Msg(message)
+
+		hostname, _ := os.Hostname()
+		expected := fmt.Sprintf(" WARN  (%d on %s): %s ring=one bearers=9\n", os.Getpid(), hostname, message)
+		require.True(t, strings.HasSuffix(out.String(), expected), out.String())
+		require.Regexp(t, `^\[\d{2}:\d{2}:\d{2}\.\d{3}\] `, out.String())
+	})
+
+	t.Run("Render a request log as an access log line", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer := NewPrettyWriter(out, PrettyOptions{NoColor: true, Location: time.UTC})
+
+		line := `{"level":30,"pid":1,"hostname":"shire","time":1618003000857,"reqId":"abc",` +
+			`"http":{"request":{"method":"GET","userAgent":{"original":"curl"}},"response":{"statusCode":200,"body":{"bytes":42}}},` +
+			`"url":{"path":"/welcome"},"host":{"hostname":"localhost","forwardedHost":"","ip":"1.2.3.4"},"responseTime":1.5,"msg":"request completed"}` + "\n"
+		n, err := writer.Write([]byte(line))
+		require.NoError(t, err)
+		require.Equal(t, len(line), n)
+
+		require.Equal(t, `[21:16:40.857] INFO  (1 on shire) [abc]: request completed GET /welcome 200 1.5ms 42B "curl" host.hostname=localhost host.ip=1.2.3.4`+"\n", out.String())
+	})
+
+	t.Run("Render levels, times and errors in any zeropino format", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer := NewPrettyWriter(out, PrettyOptions{NoColor: true, Location: time.UTC, TimeLayout: time.RFC3339})
+
+		_, err := writer.Write([]byte(`{"level":"50","time":1618003000,"err":{"type":"*errors.errorString","message":"boom","stack":"main.main\n\tmain.go:42"},"msg":"failed"}` + "\n"))
+		require.NoError(t, err)
+		_, err = writer.Write([]byte(`{"level":"debug","time":"2021-04-09T23:16:40.857+02:00","msg":"debugging"}` + "\n"))
+		require.NoError(t, err)
+
+		require.Equal(t, "[2021-04-09T21:16:40Z] ERROR: failed err=\"boom\" (*errors.errorString)\n"+
+			"    main.main\n"+
+			"    \tmain.go:42\n"+
+			"[2021-04-09T21:16:40Z] DEBUG: debugging\n", out.String())
+	})
+
+	t.Run("Render the fields of custom keys", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer := NewPrettyWriter(out, PrettyOptions{
+			NoColor:     true,
+			Location:    time.UTC,
+			TimeKey:     "at",
+			PidKey:      "processId",
+			HostnameKey: "pod",
+			MessageKey:  "message",
+			LabelKey:    "severity",
+		})
+
+		_, err := writer.Write([]byte(`{"level":30,"severity":"info","processId":1,"pod":"shire","at":1618003000857,` +
+			`"ring":"one","message":"renamed"}` + "\n"))
+		require.NoError(t, err)
+		require.Equal(t, "[21:16:40.857] INFO  (1 on shire): renamed ring=one\n", out.String())
+	})
+
+	t.Run("Write lines that are not JSON objects unchanged", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer := NewPrettyWriter(out, PrettyOptions{})
+
+		_, err := writer.Write([]byte("panic: one does not simply walk\n[1, 2]\n"))
+		require.NoError(t, err)
+		require.Equal(t, "panic: one does not simply walk\n[1, 2]\n", out.String())
+	})
+
+	t.Run("Colorize the level", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer := NewPrettyWriter(out, PrettyOptions{})
+
+		_, err := writer.Write([]byte(`{"level":"40","msg":"careful"}`))
+		require.NoError(t, err)
+		require.Equal(t, colorYellow+"WARN "+colorReset+": "+colorCyan+"careful"+colorReset+"\n", out.String())
+	})
+
+	t.Run("Report the errors of the destination", func(t *testing.T) {
+		writer := NewPrettyWriter(failingWriter{}, PrettyOptions{})
+
+		_, err := writer.Write([]byte(`{"level":"30","msg":"lost"}`))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,379 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultCensor = "[Redacted]"
+	wildcard      = "*"
+)
+
+var errMalformedJSON = errors.New("malformed JSON")
+
+// RedactOptions configures which fields are censored in each log, as pino redact option does
+type RedactOptions struct {
+	// Paths of the fields to censor, where keys are separated by dots. A key can be
+	// written between brackets and quotes, e.g. req.headers["x-api-key"], and
+	// the * wildcard matches any key of an object or any item of an array,
+	// e.g. *.password or users[*].email
+	Paths []string
+	// Censor is the value replacing the censored fields, "[Redacted]" by default
+	Censor string
+	// Remove selects whether the censored fields are removed instead of being replaced
+	Remove bool
+}
+
+// redactNode is a step of the redaction paths, shared by the paths with the same prefix
+type redactNode struct {
+	children map[string]*redactNode
+	wildcard *redactNode
+	terminal bool
+}
+
+func (n *redactNode) child(key string) *redactNode {
+	if key == wildcard {
+		if n.wildcard == nil {
+			n.wildcard = &redactNode{}
+		}
+		return n.wildcard
+	}
+
+	if n.children == nil {
+		n.children = map[string]*redactNode{}
+	}
+	if _, ok := n.children[key]; !ok {
+		n.children[key] = &redactNode{}
+	}
+	return n.children[key]
+}
+
+// redactor rewrites JSON logs censoring the fields matching its paths,
+// preserving the order of all the other fields
+type redactor struct {
+	root   *redactNode
+	censor []byte
+	remove bool
+}
+
+func newRedactor(options RedactOptions) (*redactor, error) {
+	r := &redactor{root: &redactNode{}, remove: options.Remove}
+
+	censor := options.Censor
+	if censor == "" {
+		censor = defaultCensor
+	}
+	// a string is always encoded without errors
+	r.censor, _ = json.Marshal(censor)
+
+	var errs []error
+	for _, path := range options.Paths {
+		keys, err := parseRedactPath(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("redact path %s: %w", path, err))
+			continue
+		}
+
+		node := r.root
+		for _, key := range keys {
+			node = node.child(key)
+		}
+		node.terminal = true
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return r, nil
+}
+
+// parseRedactPath splits a path into its keys, e.g. a.b["c.d"][*] into a, b, c.d and *
+func parseRedactPath(path string) ([]string, error) {
+	var keys []string
+
+	for i := 0; i < len(path); {
+		switch {
+		case path[i] == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, errors.New("unclosed bracket")
+			}
+
+			key := path[i+1 : i+end]
+			if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+				key = key[1 : len(key)-1]
+			} else if key != wildcard {
+				return nil, errors.New("bracket keys must be quoted or be a wildcard")
+			}
+
+			keys = append(keys, key)
+			i += end + 1
+		case path[i] == '.' && len(keys) > 0 && i+1 < len(path) && path[i+1] != '.' && path[i+1] != '[':
+			i++
+		case path[i] == '.':
+			return nil, errors.New("empty key")
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			keys = append(keys, path[i:i+end])
+			i += end
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("empty path")
+	}
+	return keys, nil
+}
+
+// appendRedacted appends to dst the log p with the matching fields censored.
+// Logs that do not start as JSON objects, such as plain text written directly to
+// the logger, have no field to match and are appended unchanged. Instead, a JSON
+// object that cannot be parsed is replaced as a whole by the censor value,
+// since any of its fields could be one to hide.
+func (r *redactor) appendRedacted(dst, p []byte) []byte {
+	start := skipSpaces(p, 0)
+	if start >= len(p) || p[start] != '{' {
+		return append(dst, p...)
+	}
+
+	redacted, end, err := r.appendObject(append(dst, p[:start]...), p, start, []*redactNode{r.root})
+	if err != nil {
+		dst = append(dst, r.censor...)
+		if bytes.HasSuffix(p, []byte{'\n'}) {
+			dst = append(dst, '\n')
+		}
+		return dst
+	}
+	return append(redacted, p[end:]...)
+}
+
+// appendObject rewrites the object starting at p[i], whose fields are matched against
+// the children of nodes, returning the index following the object
+func (r *redactor) appendObject(dst, p []byte, i int, nodes []*redactNode) ([]byte, int, error) {
+	dst = append(dst, '{')
+	i = skipSpaces(p, i+1)
+	first := true
+
+	for i < len(p) && p[i] != '}' {
+		if p[i] == ',' {
+			i = skipSpaces(p, i+1)
+			continue
+		}
+
+		keyEnd, err := skipString(p, i)
+		if err != nil {
+			return nil, 0, err
+		}
+		key := p[i:keyEnd]
+
+		valueStart := skipSpaces(p, keyEnd)
+		if valueStart >= len(p) || p[valueStart] != ':' {
+			return nil, 0, errMalformedJSON
+		}
+		valueStart = skipSpaces(p, valueStart+1)
+		valueEnd, err := skipValue(p, valueStart)
+		if err != nil {
+			return nil, 0, err
+		}
+		i = skipSpaces(p, valueEnd)
+
+		matches, terminal := matchKey(nodes, unquoteKey(key), false)
+		if terminal && r.remove {
+			continue
+		}
+
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = append(append(dst, key...), ':')
+
+		if dst, err = r.appendValue(dst, p, valueStart, valueEnd, matches, terminal); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if i >= len(p) {
+		return nil, 0, errMalformedJSON
+	}
+	return append(dst, '}'), i + 1, nil
+}
+
+// appendArray rewrites the array starting at p[i], whose items are matched
+// against the wildcard children of nodes, returning the index following the array
+func (r *redactor) appendArray(dst, p []byte, i int, nodes []*redactNode) ([]byte, int, error) {
+	dst = append(dst, '[')
+	i = skipSpaces(p, i+1)
+	first := true
+
+	matches, terminal := matchKey(nodes, nil, true)
+	for i < len(p) && p[i] != ']' {
+		if p[i] == ',' {
+			i = skipSpaces(p, i+1)
+			continue
+		}
+
+		valueEnd, err := skipValue(p, i)
+		if err != nil {
+			return nil, 0, err
+		}
+		valueStart := i
+		i = skipSpaces(p, valueEnd)
+
+		if terminal && r.remove {
+			continue
+		}
+
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+
+		if dst, err = r.appendValue(dst, p, valueStart, valueEnd, matches, terminal); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if i >= len(p) {
+		return nil, 0, errMalformedJSON
+	}
+	return append(dst, ']'), i + 1, nil
+}
+
+// appendValue appends the value p[start:end], censoring it when terminal
+// or rewriting it when it is a container matched by some nodes
+func (r *redactor) appendValue(dst, p []byte, start, end int, matches []*redactNode, terminal bool) ([]byte, error) {
+	switch {
+	case terminal:
+		return append(dst, r.censor...), nil
+	case len(matches) > 0 && p[start] == '{':
+		redacted, _, err := r.appendObject(dst, p, start, matches)
+		return redacted, err
+	case len(matches) > 0 && p[start] == '[':
+		redacted, _, err := r.appendArray(dst, p, start, matches)
+		return redacted, err
+	default:
+		return append(dst, p[start:end]...), nil
+	}
+}
+
+// matchKey returns the children of nodes matching the key, reporting whether any of them
+// ends a path. Array items match only wildcards.
+func matchKey(nodes []*redactNode, key []byte, arrayItem bool) ([]*redactNode, bool) {
+	var matches []*redactNode
+	terminal := false
+
+	for _, node := range nodes {
+		if child, ok := node.children[string(key)]; ok && !arrayItem {
+			matches = append(matches, child)
+			terminal = terminal || child.terminal
+		}
+		if node.wildcard != nil {
+			matches = append(matches, node.wildcard)
+			terminal = terminal || node.wildcard.terminal
+		}
+	}
+
+	return matches, terminal
+}
+
+// unquoteKey returns the content of a JSON string, avoiding allocations when it is not escaped
+func unquoteKey(key []byte) []byte {
+	if bytes.IndexByte(key, '\\') < 0 {
+		return key[1 : len(key)-1]
+	}
+
+	var unquoted string
+	if err := json.Unmarshal(key, &unquoted); err != nil {
+		return key[1 : len(key)-1]
+	}
+	return []byte(unquoted)
+}
+
+func skipSpaces(p []byte, i int) int {
+	for i < len(p) && (p[i] == ' ' || p[i] == '\t' || p[i] == '\n' || p[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipString returns the index following the string starting at p[i]
+func skipString(p []byte, i int) (int, error) {
+	if i >= len(p) || p[i] != '"' {
+		return 0, errMalformedJSON
+	}
+
+	for i++; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, errMalformedJSON
+}
+
+// skipValue returns the index following the value starting at p[i]
+func skipValue(p []byte, i int) (int, error) {
+	if i >= len(p) {
+		return 0, errMalformedJSON
+	}
+
+	switch p[i] {
+	case '"':
+		return skipString(p, i)
+	case '{', '[':
+		depth := 0
+		for ; i < len(p); i++ {
+			switch p[i] {
+			case '"':
+				end, err := skipString(p, i)
+				if err != nil {
+					return 0, err
+				}
+				i = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			}
+		}
+		return 0, errMalformedJSON
+	default:
+		start := i
+		for i < len(p) && p[i] != ',' && p[i] != '}' && p[i] != ']' && p[i] != ' ' && p[i] != '\n' {
+			i++
+		}
+		if i == start {
+			return 0, errMalformedJSON
+		}
+		return i, nil
+	}
+}
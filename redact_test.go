@@ -0,0 +1,177 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+const redactableEvent = `{"level":"30","reqId":"my-id","http":{"request":{"method":"GET","headers":{"authorization":"Bearer secret","x-api-key":"key"}}},` +
+	`"user":{"name":"frodo","password":"ring"},"items":[{"password":"one"},{"password":"two"}],"msg":"hello"}` + "\n"
+
+func TestRedact(t *testing.T) {
+	testCases := []struct {
+		name     string
+		options  RedactOptions
+		input    string
+		expected string
+	}{
+		{
+			name:    "censor nested path",
+			options: RedactOptions{Paths: []string{"http.request.headers.authorization"}},
+			input:   redactableEvent,
+			expected: `{"level":"30","reqId":"my-id","http":{"request":{"method":"GET","headers":{"authorization":"[Redacted]","x-api-key":"key"}}},` +
+				`"user":{"name":"frodo","password":"ring"},"items":[{"password":"one"},{"password":"two"}],"msg":"hello"}` + "\n",
+		},
+		{
+			name:    "censor wildcard and bracket paths",
+			options: RedactOptions{Paths: []string{"*.password", `http.request.headers["x-api-key"]`}, Censor: "***"},
+			input:   redactableEvent,
+			expected: `{"level":"30","reqId":"my-id","http":{"request":{"method":"GET","headers":{"authorization":"Bearer secret","x-api-key":"***"}}},` +
+				`"user":{"name":"frodo","password":"***"},"items":[{"password":"one"},{"password":"two"}],"msg":"hello"}` + "\n",
+		},
+		{
+			name:    "censor array items",
+			options: RedactOptions{Paths: []string{"items[*].password"}},
+			input:   redactableEvent,
+			expected: `{"level":"30","reqId":"my-id","http":{"request":{"method":"GET","headers":{"authorization":"Bearer secret","x-api-key":"key"}}},` +
+				`"user":{"name":"frodo","password":"ring"},"items":[{"password":"[Redacted]"},{"password":"[Redacted]"}],"msg":"hello"}` + "\n",
+		},
+		{
+			name:    "censor whole objects",
+			options: RedactOptions{Paths: []string{"http", "items"}},
+			input:   redactableEvent,
+			expected: `{"level":"30","reqId":"my-id","http":"[Redacted]",` +
+				`"user":{"name":"frodo","password":"ring"},"items":"[Redacted]","msg":"hello"}` + "\n",
+		},
+		{
+			name:    "remove fields",
+			options: RedactOptions{Paths: []string{"reqId", "user.password", "http.request.headers.*"}, Remove: true},
+			input:   redactableEvent,
+			expected: `{"level":"30","http":{"request":{"method":"GET","headers":{}}},` +
+				`"user":{"name":"frodo"},"items":[{"password":"one"},{"password":"two"}],"msg":"hello"}` + "\n",
+		},
+		{
+			name:     "missing paths leave the event unchanged",
+			options:  RedactOptions{Paths: []string{"user.password.value", "missing"}},
+			input:    redactableEvent,
+			expected: redactableEvent,
+		},
+		{
+			name:     "escaped keys and values",
+			options:  RedactOptions{Paths: []string{"a\"b", "msg"}},
+			input:    `{"a\"b":"x","c":"y \"}\" z","msg":"hello"}`,
+			expected: `{"a\"b":"[Redacted]","c":"y \"}\" z","msg":"[Redacted]"}`,
+		},
+		{
+			name:     "malformed events are censored as a whole",
+			options:  RedactOptions{Paths: []string{"msg"}},
+			input:    `{"msg":"hello"`,
+			expected: `"[Redacted]"`,
+		},
+		{
+			name:     "malformed events never leak the censored fields",
+			options:  RedactOptions{Paths: []string{"user.password"}, Remove: true},
+			input:    `{"level":"30","user":{"name":"frodo","password":"ring"},"msg":"hello` + "\n",
+			expected: `"[Redacted]"` + "\n",
+		},
+		{
+			name:     "plain text logs are left unchanged",
+			options:  RedactOptions{Paths: []string{"msg"}},
+			input:    "msg: hello\n",
+			expected: "msg: hello\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r, err := newRedactor(testCase.options)
+			require.NoError(t, err)
+
+			require.Equal(t, testCase.expected, string(r.appendRedacted(nil, []byte(testCase.input))))
+		})
+	}
+
+	t.Run("Invalid paths are reported", func(t *testing.T) {
+		_, err := newRedactor(RedactOptions{Paths: []string{"", "a..b", "a[b]", "a[\"b\""}})
+
+		require.EqualError(t, err, "redact path : empty path\n"+
+			"redact path a..b: empty key\n"+
+			"redact path a[b]: bracket keys must be quoted or be a wildcard\n"+
+			"redact path a[\"b\": unclosed bracket")
+	})
+
+	t.Run("Initialize a Logger with redaction", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{
+			Writer: out,
+			Redact: &RedactOptions{Paths: []string{"user.password"}},
+		})
+		verifyInit(t, logger, err, zerolog.InfoLevel)
+
+		logger.Info().Dict("user", zerolog.Dict().Str("password", "ring")).Msg(message)
+		require.Contains(t, out.String(), `"user":{"password":"[Redacted]"}`)
+		require.Contains(t, out.String(), `"msg":"Follow the spiders!"`)
+	})
+}
+
+func BenchmarkRedact(b *testing.B) {
+	b.Run("without redaction", func(b *testing.B) {
+		logger, _ := Init(InitOptions{Writer: &bytes.Buffer{}})
+		benchmarkRedactableLog(b, logger)
+	})
+
+	b.Run("with redaction", func(b *testing.B) {
+		logger, _ := Init(InitOptions{
+			Writer: &bytes.Buffer{},
+			Redact: &RedactOptions{Paths: []string{"http.request.headers.authorization", "*.password"}},
+		})
+		benchmarkRedactableLog(b, logger)
+	})
+
+	b.Run("with removal", func(b *testing.B) {
+		logger, _ := Init(InitOptions{
+			Writer: &bytes.Buffer{},
+			Redact: &RedactOptions{Paths: []string{"http.request.headers.authorization", "*.password"}, Remove: true},
+		})
+		benchmarkRedactableLog(b, logger)
+	})
+}
+
+func benchmarkRedactableLog(b *testing.B, logger *zerolog.Logger) {
+	b.Helper()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		logger.Info().
+			Dict("http", zerolog.Dict().
+				Dict("request", zerolog.Dict().
+					Str("method", "GET").
+					Dict("headers", zerolog.Dict().
+						Str("authorization", "Bearer secret").
+						Str("accept", "application/json"),
+					),
+				),
+			).
+			Dict("user", zerolog.Dict().Str("name", "frodo").Str("password", "ring")).
+			Msg(message)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+const defaultNeverSampleFrom = zerolog.ErrorLevel
+
+// LevelSampling configures the sampling of the events of a single level
+type LevelSampling struct {
+	// N keeps one event every N, where 0 and 1 keep all of them
+	N uint32
+	// Burst keeps up to Burst events every Period, before applying N sampling
+	// to the following ones. It is disabled when any of the two is zero.
+	Burst  uint32
+	Period time.Duration
+}
+
+// SamplingOptions configures the sampling of the events produced by the logger
+type SamplingOptions struct {
+	// Levels configures the sampling of each level, identified by the names accepted by InitOptions.Level
+	Levels map[string]LevelSampling
+	// NeverSampleFrom is the lowest level whose events are always kept, "error" by default.
+	// It takes precedence over the configuration of Levels.
+	NeverSampleFrom string
+}
+
+// levelFilter compares the events with the logger level, either fixed or atomic,
+// through their pino values, so that custom levels are ordered as expected
+type levelFilter struct {
+	level *AtomicLevel
+	// minimum is the fixed logger level, used when there is no atomic level
+	minimum zerolog.Level
+}
+
+// minimumLevel returns the lowest level currently logged
+func (f levelFilter) minimumLevel() zerolog.Level {
+	if f.level != nil {
+		return f.level.Level()
+	}
+	return f.minimum
+}
+
+// enabled reports whether the events of the given level are logged
+func (f levelFilter) enabled(level zerolog.Level) bool {
+	// zerolog compares the global level by number, which places custom levels above any of them
+	if pino.IsCustomLevel(level) && !pino.Enabled(level, zerolog.GlobalLevel()) {
+		return false
+	}
+	return pino.Enabled(level, f.minimumLevel())
+}
+
+// levelHook discards the events below the logger level. Derived loggers can replace
+// the sampler and zerolog.DisableSampling turns all of them off, so the level is
+// enforced by this hook as well, while the sampler drops the events before they are built.
+type levelHook struct {
+	filter levelFilter
+}
+
+// Run implements zerolog.Hook interface
+func (h levelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if !h.filter.enabled(level) {
+		e.Discard()
+	}
+}
+
+// sampler decides whether an event is logged, according to the logger level,
+// either fixed or atomic, and to the sampling of each level, when they are set.
+// Zerolog evaluates samplers before building an event, so filtered events
+// have the same cost of the ones filtered by a fixed logger level
+type sampler struct {
+	filter          levelFilter
+	neverSampleFrom zerolog.Level
+	samplers        map[zerolog.Level]zerolog.Sampler
+}
+
+func newSampler(filter levelFilter, options *SamplingOptions) (*sampler, error) {
+	s := &sampler{filter: filter, neverSampleFrom: zerolog.Disabled}
+	if options == nil {
+		return s, nil
+	}
+
+	var errs []error
+
+	s.samplers = map[zerolog.Level]zerolog.Sampler{}
+	s.neverSampleFrom = defaultNeverSampleFrom
+	if options.NeverSampleFrom != "" {
+		neverSampleFrom, err := pino.ParseLevel(options.NeverSampleFrom)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sampling: %w", err))
+		}
+		s.neverSampleFrom = neverSampleFrom
+	}
+
+	for name, levelSampling := range options.Levels {
+		level, err := pino.ParseLevel(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sampling: %w", err))
+			continue
+		}
+		s.samplers[level] = levelSampling.sampler()
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return s, nil
+}
+
+// sampler returns the zerolog sampler matching the configuration, if any
+func (ls LevelSampling) sampler() zerolog.Sampler {
+	var basic zerolog.Sampler
+	if ls.N > 1 {
+		basic = &zerolog.BasicSampler{N: ls.N}
+	}
+
+	if ls.Burst == 0 || ls.Period == 0 {
+		return basic
+	}
+
+	// events exceeding the burst are dropped when there is no basic sampling
+	return &zerolog.BurstSampler{Burst: ls.Burst, Period: ls.Period, NextSampler: basic}
+}
+
+// Sample implements zerolog.Sampler interface
+func (s *sampler) Sample(level zerolog.Level) bool {
+	if !s.filter.enabled(level) {
+		return false
+	}
+
+	if pino.Enabled(level, s.neverSampleFrom) {
+		return true
+	}
+
+	if levelSampler := s.samplers[level]; levelSampler != nil {
+		return levelSampler.Sample(level)
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampling(t *testing.T) {
+	t.Run("Keep one event every N", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{
+			Level:  "trace",
+			Writer: out,
+			Sampling: &SamplingOptions{
+				Levels: map[string]LevelSampling{"debug": {N: 5}},
+			},
+		})
+		verifyInit(t, logger, err, zerolog.TraceLevel)
+
+		for i := 0; i < 20; i++ {
+			logger.Debug().Msg(message)
+			logger.Info().Msg(message)
+		}
+
+		require.Equal(t, 4, strings.Count(out.String(), `"level":"20"`), "Debug events are sampled")
+		require.Equal(t, 20, strings.Count(out.String(), `"level":"30"`), "Info events are not sampled")
+	})
+
+	t.Run("Keep a burst of events every period", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{
+			Level:  "trace",
+			Writer: out,
+			Sampling: &SamplingOptions{
+				Levels: map[string]LevelSampling{"trace": {Burst: 3, Period: time.Hour}},
+			},
+		})
+		verifyInit(t, logger, err, zerolog.TraceLevel)
+
+		for i := 0; i < 10; i++ {
+			logger.Trace().Msg(message)
+		}
+
+		require.Equal(t, 3, strings.Count(out.String(), "\n"))
+	})
+
+	t.Run("Error and higher levels are never sampled by default", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{
+			Writer: out,
+			Sampling: &SamplingOptions{
+				Levels: map[string]LevelSampling{
+					"warn":  {N: 10},
+					"error": {N: 10},
+				},
+			},
+		})
+		verifyInit(t, logger, err, zerolog.InfoLevel)
+
+		for i := 0; i < 10; i++ {
+			logger.Warn().Msg(message)
+			logger.Error().Msg(message)
+		}
+
+		require.Equal(t, 1, strings.Count(out.String(), `"level":"40"`))
+		require.Equal(t, 10, strings.Count(out.String(), `"level":"50"`))
+	})
+
+	t.Run("Sample levels below a custom never sampled level", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{
+			Writer: out,
+			Sampling: &SamplingOptions{
+				Levels:          map[string]LevelSampling{"error": {N: 10}},
+				NeverSampleFrom: "fatal",
+			},
+		})
+		verifyInit(t, logger, err, zerolog.InfoLevel)
+
+		for i := 0; i < 10; i++ {
+			logger.Error().Msg(message)
+		}
+
+		require.Equal(t, 1, strings.Count(out.String(), "\n"))
+	})
+
+	t.Run("Sampling works together with the atomic level", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		level := NewAtomicLevel()
+		logger, err := Init(InitOptions{
+			Writer:      out,
+			AtomicLevel: level,
+			Sampling: &SamplingOptions{
+				Levels: map[string]LevelSampling{"debug": {N: 2}},
+			},
+		})
+		require.NoError(t, err)
+
+		logger.Debug().Msg(message)
+		require.Equal(t, 0, out.Len(), "Debug level is not enabled")
+
+		level.SetLevel(zerolog.DebugLevel)
+		for i := 0; i < 4; i++ {
+			logger.Debug().Msg(message)
+		}
+		require.Equal(t, 2, strings.Count(out.String(), "\n"))
+	})
+
+	t.Run("Invalid sampling levels are reported", func(t *testing.T) {
+		logger, err := Init(InitOptions{
+			Sampling: &SamplingOptions{
+				Levels:          map[string]LevelSampling{"verbose": {N: 2}},
+				NeverSampleFrom: "critical",
+			},
+		})
+
+		var emptyPointer *zerolog.Logger
+		require.Equal(t, emptyPointer, logger)
+		require.EqualError(t, err, "sampling: level critical is not recognized\nsampling: level verbose is not recognized")
+	})
+}
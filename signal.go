@@ -0,0 +1,37 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"github.com/rs/zerolog"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+// stepLevel moves the atomic level along pino levels, lowering it for negative steps,
+// and logs the transition with the notifier logger
+func stepLevel(level *AtomicLevel, notifier zerolog.Logger, signalName string, steps int) {
+	previous := level.Level()
+	current := pino.StepLevel(previous, steps)
+	level.SetLevel(current)
+
+	notifier.Info().
+		Str("signal", signalName).
+		Str("previousLevel", pino.LevelName(previous)).
+		Str("currentLevel", pino.LevelName(current)).
+		Msg("log level changed")
+}
@@ -0,0 +1,37 @@
+//go:build !unix
+
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"errors"
+
+	"github.com/rs/zerolog"
+)
+
+var errSignalsNotSupported = errors.New("signals are not supported on this platform")
+
+// watchLevelSignals reports an error, since SIGUSR1 and SIGUSR2 are not available
+func watchLevelSignals(_ *AtomicLevel, _ zerolog.Logger) (stop func(), err error) {
+	return nil, errSignalsNotSupported
+}
+
+// watchReopenSignal reports an error, since SIGHUP is not available
+func watchReopenSignal(_ *FileWriter) (stop func(), err error) {
+	return nil, errSignalsNotSupported
+}
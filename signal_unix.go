@@ -0,0 +1,82 @@
+//go:build unix
+
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/rs/zerolog"
+)
+
+// watchSignals calls handle for each received signal, until the returned function is called.
+// Stopping restores the default behavior of the signals, unless the program is notified of them
+// elsewhere: SIGHUP, SIGUSR1 and SIGUSR2 terminate the process by default.
+func watchSignals(handle func(os.Signal), signals ...os.Signal) (stop func()) {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case s := <-received:
+				handle(s)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// watchLevelSignals lowers the level by one step at each SIGUSR1 and raises it at each SIGUSR2.
+// Once stopped, these signals terminate the process again.
+func watchLevelSignals(level *AtomicLevel, notifier zerolog.Logger) (stop func(), err error) {
+	return watchSignals(func(received os.Signal) {
+		steps := 1
+		if received == syscall.SIGUSR1 {
+			steps = -1
+		}
+		stepLevel(level, notifier, received.String(), steps)
+	}, syscall.SIGUSR1, syscall.SIGUSR2), nil
+}
+
+// watchReopenSignal reopens the file at each SIGHUP
+func watchReopenSignal(w *FileWriter) (stop func(), err error) {
+	return watchSignals(func(os.Signal) {
+		// a failed reopen is reported by the following writes
+		_ = w.Reopen()
+	}, syscall.SIGHUP), nil
+}
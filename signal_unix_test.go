@@ -0,0 +1,108 @@
+//go:build unix
+
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+type levelTransitionLog struct {
+	miaLog
+	Signal        string `json:"signal"`
+	PreviousLevel string `json:"previousLevel"`
+	CurrentLevel  string `json:"currentLevel"`
+}
+
+func TestLevelSignals(t *testing.T) {
+	out := &syncBuffer{}
+	level := NewAtomicLevel()
+	logger, stop, err := InitWithStop(InitOptions{
+		Level:        "error",
+		Writer:       out,
+		AtomicLevel:  level,
+		LevelSignals: true,
+	})
+	require.Nil(t, err)
+	defer stop()
+
+	logger.Info().Msg(message)
+	require.Empty(t, out.String())
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	require.Eventually(t, func() bool {
+		return level.Level() == zerolog.WarnLevel
+	}, time.Second, 5*time.Millisecond, "SIGUSR1 lowers the level")
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	require.Eventually(t, func() bool {
+		return level.Level() == zerolog.InfoLevel
+	}, time.Second, 5*time.Millisecond, "SIGUSR1 lowers the level")
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	require.Eventually(t, func() bool {
+		return level.Level() == zerolog.WarnLevel
+	}, time.Second, 5*time.Millisecond, "SIGUSR2 raises the level")
+
+	require.Eventually(t, func() bool {
+		return strings.Count(out.String(), "\n") == 3
+	}, time.Second, 5*time.Millisecond, "Each transition is logged")
+
+	entries := strings.Split(strings.TrimSpace(out.String()), "\n")
+	result := levelTransitionLog{}
+	require.Nil(t, json.Unmarshal([]byte(entries[2]), &result), "No error raised")
+	verifyLog(t, &result.miaLog, "log level changed", string(pino.Info), unixTimestampMsLen)
+	require.Equal(t, "user defined signal 2", result.Signal)
+	require.Equal(t, "info", result.PreviousLevel)
+	require.Equal(t, "warn", result.CurrentLevel)
+
+	stop()
+	stop()
+}
+
+func TestFileWriterReopenOnSIGHUP(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "service.log")
+	writer, err := NewFileWriter(FileOptions{Filename: filename, ReopenOnSIGHUP: true})
+	require.NoError(t, err)
+	defer writer.Close()
+
+	_, err = writer.Write([]byte(logLine))
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(filename, filename+".1"))
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filename)
+		return err == nil
+	}, time.Second, 5*time.Millisecond, "File is reopened")
+
+	_, err = writer.Write([]byte(logLine))
+	require.NoError(t, err)
+	requireFileContent(t, filename, logLine)
+}
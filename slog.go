@@ -0,0 +1,261 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+// SlogHandler is a slog.Handler writing records through a zeropino logger, so that
+// log/slog records have the same shape, level configuration and destinations
+// of the logs written by the logger. Groups are written as nested objects and the
+// request ID stored in the context by zeropino middlewares is written as reqId field,
+// unless SlogHandlerOptions states that the logger already writes it.
+type SlogHandler struct {
+	logger *zerolog.Logger
+	// level is the AtomicLevel controlling the logger, if any
+	level *AtomicLevel
+	// loggerRequestID reports whether the logger writes the reqId field on its own
+	loggerRequestID bool
+	// attrs added outside any group
+	attrs []slog.Attr
+	// groups opened by WithGroup, each one with the attrs added while it was the innermost
+	groups []slogGroup
+}
+
+// requestIDField is the key of the request ID field written by zeropino middlewares
+const requestIDField = "reqId"
+
+type slogGroup struct {
+	name  string
+	attrs []slog.Attr
+}
+
+// SlogHandlerOptions are the options of a SlogHandler
+type SlogHandlerOptions struct {
+	// AtomicLevel, when set, is the AtomicLevel controlling the logger, so that the handler
+	// reports as disabled the records it would drop. It should be the one of InitOptions.
+	AtomicLevel *AtomicLevel
+	// LoggerRequestID states that the logger already writes the reqId field, as the
+	// per-request loggers of zeropino middlewares do, so that it is not written twice
+	LoggerRequestID bool
+}
+
+// NewSlogHandler creates a SlogHandler writing through logger,
+// e.g. slog.New(zeropino.NewSlogHandler(logger))
+func NewSlogHandler(logger *zerolog.Logger) *SlogHandler {
+	return NewSlogHandlerWithOptions(logger, SlogHandlerOptions{})
+}
+
+// NewSlogHandlerWithOptions creates a SlogHandler writing through logger, configured by options
+func NewSlogHandlerWithOptions(logger *zerolog.Logger, options SlogHandlerOptions) *SlogHandler {
+	return &SlogHandler{
+		logger:          logger,
+		level:           options.AtomicLevel,
+		loggerRequestID: options.LoggerRequestID,
+	}
+}
+
+// Enabled implements slog.Handler interface, according to the level of the logger
+// and to its AtomicLevel, when provided. Records reported as enabled may still be
+// dropped by its sampling, or by its AtomicLevel when it is not provided.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	zerologLevel := slogLevel(level)
+	if h.level != nil && !h.level.Enabled(zerologLevel) {
+		return false
+	}
+	return pino.Enabled(zerologLevel, h.logger.GetLevel())
+}
+
+// Handle implements slog.Handler interface
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	event := h.logger.WithLevel(slogLevel(record.Level))
+	if event == nil {
+		return nil
+	}
+
+	if requestID := RequestID(ctx); requestID != "" && !h.loggerRequestID {
+		event.Str(requestIDField, requestID)
+	}
+
+	var recordAttrs []slog.Attr
+	record.Attrs(func(attr slog.Attr) bool {
+		recordAttrs = append(recordAttrs, attr)
+		return true
+	})
+
+	appendSlogAttrs(event, h.attrs)
+	if len(h.groups) == 0 {
+		appendSlogAttrs(event, recordAttrs)
+	} else if group := h.groupDict(0, recordAttrs); group != nil {
+		event.Dict(h.groups[0].name, group)
+	}
+
+	// the time is written by the logger, as for any other event
+	event.Msg(record.Message)
+	return nil
+}
+
+// groupDict returns the object of the i-th group, nesting the following ones and the record attrs
+// into the innermost group, or nil when there is no attr to write, since empty groups are omitted
+func (h *SlogHandler) groupDict(i int, recordAttrs []slog.Attr) *zerolog.Event {
+	attrs := h.groups[i].attrs
+
+	var nested *zerolog.Event
+	if i+1 < len(h.groups) {
+		nested = h.groupDict(i+1, recordAttrs)
+	} else {
+		attrs = append(attrs[:len(attrs):len(attrs)], recordAttrs...)
+	}
+
+	if nested == nil && !hasSlogAttrs(attrs) {
+		return nil
+	}
+
+	dict := zerolog.Dict()
+	appendSlogAttrs(dict, attrs)
+	if nested != nil {
+		dict.Dict(h.groups[i+1].name, nested)
+	}
+	return dict
+}
+
+// WithAttrs implements slog.Handler interface
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	handler := h.clone()
+	if len(handler.groups) == 0 {
+		handler.attrs = append(handler.attrs, attrs...)
+		return handler
+	}
+
+	last := &handler.groups[len(handler.groups)-1]
+	last.attrs = append(last.attrs[:len(last.attrs):len(last.attrs)], attrs...)
+	return handler
+}
+
+// WithGroup implements slog.Handler interface
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	handler := h.clone()
+	handler.groups = append(handler.groups, slogGroup{name: name})
+	return handler
+}
+
+// clone returns a copy of the handler whose slices can be extended without affecting the original
+func (h *SlogHandler) clone() *SlogHandler {
+	return &SlogHandler{
+		logger:          h.logger,
+		level:           h.level,
+		loggerRequestID: h.loggerRequestID,
+		attrs:           h.attrs[:len(h.attrs):len(h.attrs)],
+		groups:          append([]slogGroup(nil), h.groups...),
+	}
+}
+
+// slogLevel converts a slog level into the zerolog one, rounding down
+// the levels between the ones defined by slog
+func slogLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zerolog.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zerolog.WarnLevel
+	case level >= slog.LevelInfo:
+		return zerolog.InfoLevel
+	case level >= slog.LevelDebug:
+		return zerolog.DebugLevel
+	default:
+		return zerolog.TraceLevel
+	}
+}
+
+// hasSlogAttrs reports whether any of the attrs is written
+func hasSlogAttrs(attrs []slog.Attr) bool {
+	for _, attr := range attrs {
+		attr.Value = attr.Value.Resolve()
+		if attr.Equal(slog.Attr{}) {
+			continue
+		}
+		if attr.Value.Kind() != slog.KindGroup || attr.Key == "" || hasSlogAttrs(attr.Value.Group()) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendSlogAttrs(e *zerolog.Event, attrs []slog.Attr) {
+	for _, attr := range attrs {
+		appendSlogAttr(e, attr)
+	}
+}
+
+// appendSlogAttr writes the attr following slog.Handler rules: empty attrs and groups
+// are omitted, while the attrs of groups with an empty key are inlined
+func appendSlogAttr(e *zerolog.Event, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		e.Str(attr.Key, attr.Value.String())
+	case slog.KindInt64:
+		e.Int64(attr.Key, attr.Value.Int64())
+	case slog.KindUint64:
+		e.Uint64(attr.Key, attr.Value.Uint64())
+	case slog.KindFloat64:
+		e.Float64(attr.Key, attr.Value.Float64())
+	case slog.KindBool:
+		e.Bool(attr.Key, attr.Value.Bool())
+	case slog.KindDuration:
+		e.Dur(attr.Key, attr.Value.Duration())
+	case slog.KindTime:
+		e.Time(attr.Key, attr.Value.Time())
+	case slog.KindGroup:
+		attrs := attr.Value.Group()
+		if attr.Key == "" {
+			appendSlogAttrs(e, attrs)
+			return
+		}
+		if !hasSlogAttrs(attrs) {
+			return
+		}
+		dict := zerolog.Dict()
+		appendSlogAttrs(dict, attrs)
+		e.Dict(attr.Key, dict)
+	default:
+		if err, ok := attr.Value.Any().(error); ok {
+			// the stack of the caller would only show slog internals
+			e.Object(attr.Key, errorObject{err: err})
+			return
+		}
+		e.Interface(attr.Key, attr.Value.Any())
+	}
+}
@@ -0,0 +1,172 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+type ringBearer struct {
+	name string
+}
+
+func (b ringBearer) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("name", b.name), slog.Bool("invisible", true))
+}
+
+func TestSlogHandler(t *testing.T) {
+	t.Run("Write records with zeropino shape", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out})
+		require.NoError(t, err)
+
+		slog.New(NewSlogHandler(logger)).Warn(message, "rings", 3)
+
+		hostname, _ := os.Hostname()
+		result := miaLog{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		verifyLog(t, &result, message, string(pino.Warn), unixTimestampMsLen)
+		require.Equal(t, os.Getpid(), result.Pid)
+		require.Equal(t, hostname, result.Hostname)
+		require.Contains(t, out.String(), `"rings":3`)
+	})
+
+	t.Run("Respect the level of the logger", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		level := NewAtomicLevel()
+		logger, err := Init(InitOptions{Writer: out, Level: "warn", AtomicLevel: level})
+		require.NoError(t, err)
+		slogger := slog.New(NewSlogHandler(logger))
+
+		slogger.Info("dropped")
+		slogger.Debug("dropped")
+		require.Zero(t, out.Len())
+		require.True(t, slogger.Enabled(context.Background(), slog.LevelInfo), "The atomic level is not known")
+
+		slogger = slog.New(NewSlogHandlerWithOptions(logger, SlogHandlerOptions{AtomicLevel: level}))
+		require.False(t, slogger.Enabled(context.Background(), slog.LevelInfo), "The atomic level is the effective one")
+		require.True(t, slogger.Enabled(context.Background(), slog.LevelWarn))
+
+		level.SetLevel(zerolog.DebugLevel)
+		slogger.Debug(message)
+		require.Contains(t, out.String(), `"level":"20"`)
+
+		fixed, err := Init(InitOptions{Writer: out, Level: "error"})
+		require.NoError(t, err)
+		handler := NewSlogHandler(fixed)
+		require.False(t, handler.Enabled(context.Background(), slog.LevelWarn))
+		require.True(t, handler.Enabled(context.Background(), slog.LevelError+2))
+	})
+
+	t.Run("Write groups as nested objects", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, OmitPid: true, OmitHostname: true, TimeFormat: TimeFormatDisabled})
+		require.NoError(t, err)
+
+		slogger := slog.New(NewSlogHandler(logger)).
+			With("service", "shire").
+			WithGroup("request").
+			With("method", "GET").
+			WithGroup("response")
+
+		slogger.Info(message, "statusCode", 200, slog.Group("body", "bytes", 42))
+		slogger.Info(message)
+		slogger.WithGroup("empty").Info(message, slog.Group("none"))
+
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		require.Len(t, lines, 3)
+		require.JSONEq(t, `{"level":"30","service":"shire","request":{"method":"GET","response":{"statusCode":200,"body":{"bytes":42}}},"msg":"Follow the spiders!"}`, string(lines[0]))
+		require.JSONEq(t, `{"level":"30","service":"shire","request":{"method":"GET"},"msg":"Follow the spiders!"}`, string(lines[1]), "Empty groups are omitted")
+		require.JSONEq(t, string(lines[1]), string(lines[2]))
+	})
+
+	t.Run("Write every kind of value", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, OmitPid: true, OmitHostname: true, TimeFormat: TimeFormatDisabled})
+		require.NoError(t, err)
+
+		slog.New(NewSlogHandler(logger)).Error(message,
+			slog.Uint64("count", 7),
+			slog.Float64("ratio", 0.5),
+			slog.Time("at", time.Date(2021, 8, 18, 10, 30, 0, 0, time.UTC)),
+			slog.Any("bearer", ringBearer{name: "frodo"}),
+			slog.Group("", slog.String("inlined", "yes")),
+			slog.Any("err", errors.New("the ring is lost")),
+			slog.Attr{},
+		)
+
+		require.JSONEq(t, `{"level":"50","count":7,"ratio":0.5,"at":"2021-08-18T10:30:00Z",`+
+			`"bearer":{"name":"frodo","invisible":true},"inlined":"yes",`+
+			`"err":{"type":"*errors.errorString","message":"the ring is lost"},"msg":"Follow the spiders!"}`, out.String())
+	})
+
+	t.Run("Write the request ID stored in the context", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out})
+		require.NoError(t, err)
+
+		ctx := WithRequestID(context.Background(), "16c9c1f2")
+		slog.New(NewSlogHandler(logger)).InfoContext(ctx, message)
+
+		result := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		require.Equal(t, "16c9c1f2", result["reqId"])
+	})
+
+	t.Run("Write the request ID once with a per-request logger", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out})
+		require.NoError(t, err)
+		reqLogger := logger.With().Str("reqId", "16c9c1f2").Logger()
+
+		ctx := WithRequestID(context.Background(), "16c9c1f2")
+		handler := NewSlogHandlerWithOptions(&reqLogger, SlogHandlerOptions{LoggerRequestID: true})
+		slog.New(handler).With("service", "shire").InfoContext(ctx, message)
+
+		require.Equal(t, 1, strings.Count(out.String(), `"reqId"`))
+	})
+
+	t.Run("Write the time the record is handled at", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, TimeKey: "at", TimeFormat: TimeFormatRFC3339Nano})
+		require.NoError(t, err)
+
+		before := time.Now()
+		recordTime := time.Date(2021, 8, 18, 10, 30, 0, 0, time.UTC)
+		record := slog.NewRecord(recordTime, slog.LevelInfo, message, 0)
+		require.NoError(t, NewSlogHandler(logger).Handle(context.Background(), record))
+
+		result := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		logged, err := time.Parse(time.RFC3339Nano, result["at"].(string))
+		require.NoError(t, err)
+		require.False(t, logged.Before(before), "The time is written by the logger")
+	})
+}
@@ -0,0 +1,112 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+const httpPanicPrefix = "http: panic serving "
+
+// stdLogPrefixes map the prefixes commonly written by code using the standard
+// library log package to the level of their messages
+var stdLogPrefixes = []struct {
+	prefix string
+	level  zerolog.Level
+}{
+	{"http: Accept error", zerolog.ErrorLevel},
+	{"[ERROR] ", zerolog.ErrorLevel},
+	{"ERROR: ", zerolog.ErrorLevel},
+	{"[WARN] ", zerolog.WarnLevel},
+	{"WARN: ", zerolog.WarnLevel},
+	{"WARNING: ", zerolog.WarnLevel},
+	{"[INFO] ", zerolog.InfoLevel},
+	{"INFO: ", zerolog.InfoLevel},
+	{"[DEBUG] ", zerolog.DebugLevel},
+	{"DEBUG: ", zerolog.DebugLevel},
+}
+
+// stdLogWriter writes each message of a standard library logger as a zeropino log
+type stdLogWriter struct {
+	logger *zerolog.Logger
+	level  zerolog.Level
+}
+
+// Write implements io.Writer interface. The standard library logger writes
+// each message with a single call, so that p is a whole message.
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	message := string(bytes.TrimRight(p, "\n"))
+	level := w.level
+	var stack string
+
+	switch {
+	case strings.HasPrefix(message, httpPanicPrefix):
+		// net/http writes the recovered value followed by the stack of the goroutine
+		level = zerolog.ErrorLevel
+		message, stack, _ = strings.Cut(message, "\n")
+	default:
+		for _, rule := range stdLogPrefixes {
+			if strings.HasPrefix(message, rule.prefix) {
+				level = rule.level
+				if strings.HasSuffix(rule.prefix, " ") {
+					message = strings.TrimPrefix(message, rule.prefix)
+				}
+				break
+			}
+		}
+	}
+
+	event := w.logger.WithLevel(level)
+	if event == nil {
+		return len(p), nil
+	}
+	if stack != "" {
+		event.Str("stack", stack)
+	}
+	event.Msg(message)
+
+	return len(p), nil
+}
+
+// NewStdLogger creates a standard library logger writing its messages through logger at the given level,
+// e.g. to be used as http.Server ErrorLog. Messages starting with common prefixes, such as "ERROR: ",
+// are written at the level they declare, while the panics recovered by net/http are written
+// at error level with their goroutine trace under the stack field.
+func NewStdLogger(logger *zerolog.Logger, level zerolog.Level) *log.Logger {
+	return log.New(stdLogWriter{logger: logger, level: level}, "", 0)
+}
+
+// RedirectStdLog makes the standard library log package write its messages through logger,
+// as the loggers created by NewStdLogger do, and returns a function that restores its previous settings
+func RedirectStdLog(logger *zerolog.Logger, level zerolog.Level) func() {
+	std := log.Default()
+	output, prefix, flags := std.Writer(), std.Prefix(), std.Flags()
+
+	std.SetOutput(stdLogWriter{logger: logger, level: level})
+	std.SetPrefix("")
+	std.SetFlags(0)
+
+	return func() {
+		std.SetOutput(output)
+		std.SetPrefix(prefix)
+		std.SetFlags(flags)
+	}
+}
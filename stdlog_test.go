@@ -0,0 +1,106 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danibix95/zeropino/pino"
+)
+
+func TestStdLogger(t *testing.T) {
+	t.Run("Write the messages of a standard library logger", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out, Level: "debug"})
+		require.NoError(t, err)
+
+		stdLogger := NewStdLogger(logger, zerolog.InfoLevel)
+		stdLogger.Printf("http: TLS handshake error from %s: EOF", "10.0.0.1:4242")
+		stdLogger.Print("WARNING: the ring is heavy")
+		stdLogger.Print("[DEBUG] second breakfast")
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 3)
+
+		expected := []struct{ level, msg string }{
+			{string(pino.Info), "http: TLS handshake error from 10.0.0.1:4242: EOF"},
+			{string(pino.Warn), "the ring is heavy"},
+			{string(pino.Debug), "second breakfast"},
+		}
+		for i, line := range lines {
+			result := miaLog{}
+			require.Nil(t, json.Unmarshal([]byte(line), &result), "No error raised")
+			verifyLog(t, &result, expected[i].msg, expected[i].level, unixTimestampMsLen)
+		}
+	})
+
+	t.Run("Write panics recovered by net/http with their stack", func(t *testing.T) {
+		out := &syncBuffer{}
+		logger, err := Init(InitOptions{Writer: out})
+		require.NoError(t, err)
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("one does not simply walk into Mordor")
+		}))
+		server.Config.ErrorLog = NewStdLogger(logger, zerolog.WarnLevel)
+		server.Start()
+		defer server.Close()
+
+		_, err = http.Get(server.URL)
+		require.Error(t, err, "The connection is closed by the panic")
+
+		require.Eventually(t, func() bool { return out.String() != "" }, time.Second, 10*time.Millisecond)
+		result := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal([]byte(out.String()), &result), "No error raised")
+		require.Equal(t, string(pino.Error), result["level"])
+		require.True(t, strings.HasPrefix(result["msg"].(string), "http: panic serving 127.0.0.1:"))
+		require.True(t, strings.HasSuffix(result["msg"].(string), ": one does not simply walk into Mordor"))
+		require.True(t, strings.HasPrefix(result["stack"].(string), "goroutine "))
+	})
+
+	t.Run("Redirect the standard library log package", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logger, err := Init(InitOptions{Writer: out})
+		require.NoError(t, err)
+
+		original := log.Writer()
+		defer log.SetOutput(original)
+
+		previous := &bytes.Buffer{}
+		log.SetOutput(previous)
+
+		restore := RedirectStdLog(logger, zerolog.InfoLevel)
+		log.Printf("ERROR: %s", "the ring is lost")
+		restore()
+		log.Print("after restore")
+
+		result := miaLog{}
+		require.Nil(t, json.Unmarshal(out.Bytes(), &result), "No error raised")
+		verifyLog(t, &result, "the ring is lost", string(pino.Error), unixTimestampMsLen)
+		require.Contains(t, previous.String(), "after restore")
+	})
+}
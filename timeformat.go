@@ -0,0 +1,95 @@
+/*
+ *   Copyright 2021 Daniele Bissoli
+ *
+ *   Licensed under the Apache License, Version 2.0 (the "License");
+ *   you may not use this file except in compliance with the License.
+ *   You may obtain a copy of the License at
+ *
+ *       http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *   Unless required by applicable law or agreed to in writing, software
+ *   distributed under the License is distributed on an "AS IS" BASIS,
+ *   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *   See the License for the specific language governing permissions and
+ *   limitations under the License.
+ */
+
+package zeropino
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// TimeFormat selects how the time of each log is written
+type TimeFormat string
+
+// Represents all the accepted time formats
+const (
+	// TimeFormatEpochMs writes the Unix timestamp in milliseconds, the default format
+	TimeFormatEpochMs TimeFormat = "ms"
+	// TimeFormatEpochSeconds writes the Unix timestamp in seconds
+	TimeFormatEpochSeconds TimeFormat = "s"
+	// TimeFormatEpochNs writes the Unix timestamp in nanoseconds
+	TimeFormatEpochNs TimeFormat = "ns"
+	// TimeFormatRFC3339 writes the local time as an ISO-8601 string with timezone, e.g. 2021-08-18T10:30:00+02:00
+	TimeFormatRFC3339 TimeFormat = "rfc3339"
+	// TimeFormatRFC3339Nano writes the local time as TimeFormatRFC3339 does, adding fractional seconds
+	TimeFormatRFC3339Nano TimeFormat = "rfc3339nano"
+	// TimeFormatDisabled omits the time from the logs
+	TimeFormatDisabled TimeFormat = "disabled"
+)
+
+// EpochTime converts a Unix timestamp written by any epoch TimeFormat, in seconds,
+// milliseconds or nanoseconds, distinguishing them by their magnitude
+func EpochTime(epoch int64) time.Time {
+	switch {
+	case epoch < 1e11:
+		return time.Unix(epoch, 0)
+	case epoch < 1e14:
+		return time.UnixMilli(epoch)
+	default:
+		return time.Unix(0, epoch)
+	}
+}
+
+// parseTimeFormat returns the time format matching the given name, ignoring its case
+func parseTimeFormat(name string) (TimeFormat, error) {
+	switch timeFormat := TimeFormat(strings.ToLower(name)); timeFormat {
+	case "":
+		return TimeFormatEpochMs, nil
+	case TimeFormatEpochMs, TimeFormatEpochSeconds, TimeFormatEpochNs,
+		TimeFormatRFC3339, TimeFormatRFC3339Nano, TimeFormatDisabled:
+		return timeFormat, nil
+	default:
+		return "", fmt.Errorf("time format %s is not recognized", name)
+	}
+}
+
+// timestampHook adds to each event the time it has been logged at,
+// replacing zerolog Timestamp which would rely on global settings
+type timestampHook struct {
+	key    string
+	format TimeFormat
+}
+
+// Run implements zerolog.Hook interface
+func (h timestampHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	now := time.Now()
+
+	switch h.format {
+	case TimeFormatEpochSeconds:
+		e.Int64(h.key, now.Unix())
+	case TimeFormatEpochNs:
+		e.Int64(h.key, now.UnixNano())
+	case TimeFormatRFC3339:
+		e.Str(h.key, now.Format(time.RFC3339))
+	case TimeFormatRFC3339Nano:
+		e.Str(h.key, now.Format(time.RFC3339Nano))
+	default:
+		e.Int64(h.key, now.UnixMilli())
+	}
+}